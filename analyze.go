@@ -0,0 +1,241 @@
+package nasa
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"image"
+	"image/color"
+	_ "image/gif" // decoders for AnalyzeImage
+	_ "image/jpeg"
+	_ "image/png"
+	"io"
+	"math"
+	"os"
+	"sort"
+)
+
+// Orientation is the orientation of an image
+type Orientation string
+
+// Image orientations
+const (
+	Landscape Orientation = "landscape"
+	Portrait  Orientation = "portrait"
+	Square    Orientation = "square"
+)
+
+// squareTolerance is how far from 1 the aspect ratio of a Square image may be
+const squareTolerance = 0.05
+
+// paletteSize is the number of dominant colors in an ImageAnalysis
+const paletteSize = 5
+
+// analyzeSamples is the number of pixels sampled along each side of the image
+const analyzeSamples = 256
+
+// ImageAnalysis describes the pixels of an APOD picture
+type ImageAnalysis struct {
+	Width       int            `json:"width"`
+	Height      int            `json:"height"`
+	AspectRatio float64        `json:"aspect_ratio"` // width / height
+	Luminance   float64        `json:"luminance"`    // mean luminance, from 0 for black to 1 for white
+	Palette     []PaletteColor `json:"palette"`      // dominant colors, most common first
+}
+
+// PaletteColor is a dominant color of an image
+type PaletteColor struct {
+	R     uint8   `json:"r"`
+	G     uint8   `json:"g"`
+	B     uint8   `json:"b"`
+	Share float64 `json:"share"` // fraction of the image close to the color
+}
+
+// RGBA implements color.Color
+func (pc PaletteColor) RGBA() (r, g, b, a uint32) {
+	return color.RGBA{pc.R, pc.G, pc.B, 0xff}.RGBA()
+}
+
+// Hex returns the color in #rrggbb notation
+func (pc PaletteColor) Hex() string {
+	return fmt.Sprintf("#%02x%02x%02x", pc.R, pc.G, pc.B)
+}
+
+// Orientation returns the orientation of the image
+func (a ImageAnalysis) Orientation() Orientation {
+	switch {
+	case math.Abs(a.AspectRatio-1) <= squareTolerance:
+		return Square
+	case a.AspectRatio > 1:
+		return Landscape
+	default:
+		return Portrait
+	}
+}
+
+// Fits reports whether the image is at least width x height pixels
+func (a ImageAnalysis) Fits(width, height int) bool {
+	return a.Width >= width && a.Height >= height
+}
+
+func (a ImageAnalysis) String() string {
+	s := fmt.Sprintf("%dx%d %s, luminance %.2f", a.Width, a.Height, a.Orientation(), a.Luminance)
+	for i, c := range a.Palette {
+		if i == 0 {
+			s += ", colors"
+		}
+		s += fmt.Sprintf(" %s (%.0f%%)", c.Hex(), c.Share*100)
+	}
+	return s
+}
+
+// AnalyzeImage decodes a JPEG, PNG or GIF image from r and analyzes it.
+// Other formats return an error matching image.ErrFormat.
+func AnalyzeImage(r io.Reader) (*ImageAnalysis, error) {
+	img, _, err := image.Decode(r)
+	if err != nil {
+		return nil, fmt.Errorf("unable to decode image: %w", err)
+	}
+	return analyze(img), nil
+}
+
+// AnalyzeImageFile analyzes the image at path, see AnalyzeImage
+func AnalyzeImageFile(path string) (*ImageAnalysis, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = f.Close() }()
+	return AnalyzeImage(f)
+}
+
+// analyze analyzes a sample of the pixels of img
+func analyze(img image.Image) *ImageAnalysis {
+	b := img.Bounds()
+	a := &ImageAnalysis{Width: b.Dx(), Height: b.Dy()}
+	if a.Width == 0 || a.Height == 0 {
+		return a
+	}
+	a.AspectRatio = float64(a.Width) / float64(a.Height)
+
+	// colors are counted in buckets of 3 bits per channel, averaging the colors in each
+	type bucket struct{ n, r, g, b int }
+	var buckets [512]bucket
+	var lum float64
+	n := 0
+	stepX, stepY := sampleStep(a.Width), sampleStep(a.Height)
+	for y := b.Min.Y; y < b.Max.Y; y += stepY {
+		for x := b.Min.X; x < b.Max.X; x += stepX {
+			c := color.NRGBAModel.Convert(img.At(x, y)).(color.NRGBA)
+			lum += 0.2126*float64(c.R) + 0.7152*float64(c.G) + 0.0722*float64(c.B)
+			bk := &buckets[int(c.R>>5)<<6|int(c.G>>5)<<3|int(c.B>>5)]
+			bk.n++
+			bk.r += int(c.R)
+			bk.g += int(c.G)
+			bk.b += int(c.B)
+			n++
+		}
+	}
+	a.Luminance = lum / float64(n) / 255
+
+	sorted := buckets[:]
+	sort.SliceStable(sorted, func(i, j int) bool { return sorted[i].n > sorted[j].n })
+	for _, bk := range sorted[:paletteSize] {
+		if bk.n == 0 {
+			break
+		}
+		a.Palette = append(a.Palette, PaletteColor{
+			R:     uint8(bk.r / bk.n),
+			G:     uint8(bk.g / bk.n),
+			B:     uint8(bk.b / bk.n),
+			Share: float64(bk.n) / float64(n),
+		})
+	}
+	return a
+}
+
+// sampleStep returns the distance between pixels sampled along a side of size pixels
+func sampleStep(size int) int {
+	if step := size / analyzeSamples; step > 1 {
+		return step
+	}
+	return 1
+}
+
+// AnalyzeAPOD downloads and analyzes the HD picture of the APOD (the thumbnail for videos),
+// using DefaultClient. See Client.AnalyzeAPODContext.
+func AnalyzeAPOD(apod Image, opts ...DownloadOption) (*ImageAnalysis, error) {
+	return DefaultClient.AnalyzeAPODContext(context.Background(), apod, opts...)
+}
+
+// AnalyzeAPODContext is like AnalyzeAPOD but uses ctx for the requests
+func AnalyzeAPODContext(ctx context.Context, apod Image, opts ...DownloadOption) (*ImageAnalysis, error) {
+	return DefaultClient.AnalyzeAPODContext(ctx, apod, opts...)
+}
+
+// AnalyzeAPOD downloads and analyzes the HD picture of the APOD (the thumbnail for videos)
+func (c *Client) AnalyzeAPOD(apod Image, opts ...DownloadOption) (*ImageAnalysis, error) {
+	return c.AnalyzeAPODContext(context.Background(), apod, opts...)
+}
+
+// AnalyzeAPODContext is like AnalyzeAPOD but uses ctx for the requests. Analyses are
+// stored in the client's cache next to the APOD metadata, keyed by the picture URL,
+// so a picture is only downloaded the first time it's analyzed.
+func (c *Client) AnalyzeAPODContext(ctx context.Context, apod Image, opts ...DownloadOption) (*ImageAnalysis, error) {
+	rawurl := apod.PictureURL(!newDownloadOptions(opts).sd)
+	if rawurl == "" {
+		return nil, ErrNoPicture
+	}
+	key := analysisCacheKey(rawurl)
+	if c.cache != nil {
+		if dat, ok := c.cache.Get(key); ok {
+			var a ImageAnalysis
+			if err := json.Unmarshal(dat, &a); err == nil {
+				return &a, nil
+			}
+		}
+	}
+	var buf bytes.Buffer
+	if _, err := c.DownloadImageContext(ctx, apod, &buf, opts...); err != nil {
+		return nil, err
+	}
+	a, err := AnalyzeImage(&buf)
+	if err != nil {
+		return nil, err
+	}
+	if c.cache != nil {
+		if dat, err := json.Marshal(a); err == nil {
+			c.cache.Set(key, dat, 0)
+		}
+	}
+	return a, nil
+}
+
+// analysisCacheKey is the cache key of the analysis of the picture at rawurl
+func analysisCacheKey(rawurl string) string { return "analysis:" + rawurl }
+
+// Analyze returns the analysis of the picture of the APOD of d, analyzing the picture
+// and saving the result in the index if it has not been analyzed yet
+func (m *Mirror) Analyze(d Date) (*ImageAnalysis, error) {
+	e, ok := m.Entry(d)
+	if !ok {
+		return nil, fmt.Errorf("no APOD on %s in the mirror", d)
+	}
+	if e.Analysis != nil {
+		return e.Analysis, nil
+	}
+	if e.File == "" {
+		return nil, fmt.Errorf("%w: the picture of %s has not been downloaded", ErrNoPicture, d)
+	}
+	a, err := AnalyzeImageFile(m.PicturePath(e))
+	if err != nil {
+		return nil, err
+	}
+	m.mu.Lock()
+	if pe, ok := m.entries[d]; ok {
+		pe.Analysis = a
+	}
+	m.mu.Unlock()
+	return a, m.save()
+}
@@ -0,0 +1,142 @@
+package nasa
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"image"
+	"image/color"
+	"image/png"
+	"math"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/peteretelej/nasa/nasatest"
+)
+
+func TestAnalyzeImage(t *testing.T) {
+	// left three quarters black, the rest white
+	img := image.NewRGBA(image.Rect(0, 0, 400, 100))
+	for y := 0; y < 100; y++ {
+		for x := 0; x < 400; x++ {
+			c := color.RGBA{0, 0, 0, 255}
+			if x >= 300 {
+				c = color.RGBA{255, 255, 255, 255}
+			}
+			img.SetRGBA(x, y, c)
+		}
+	}
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, img); err != nil {
+		t.Fatal(err)
+	}
+	a, err := AnalyzeImage(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if a.Width != 400 || a.Height != 100 || a.AspectRatio != 4 || a.Orientation() != Landscape {
+		t.Errorf("AnalyzeImage returned wrong dimensions got %dx%d (%v, %s), want 400x100 landscape", a.Width, a.Height, a.AspectRatio, a.Orientation())
+	}
+	if math.Abs(a.Luminance-0.25) > 0.01 {
+		t.Errorf("AnalyzeImage returned wrong luminance got %v, want %v", a.Luminance, 0.25)
+	}
+	want := []PaletteColor{{0, 0, 0, 0.75}, {255, 255, 255, 0.25}}
+	if len(a.Palette) != len(want) {
+		t.Fatalf("AnalyzeImage returned wrong palette got %v, want %v", a.Palette, want)
+	}
+	for i, c := range a.Palette {
+		if c.Hex() != want[i].Hex() || math.Abs(c.Share-want[i].Share) > 0.01 {
+			t.Errorf("AnalyzeImage returned wrong palette color %d got %+v, want %+v", i, c, want[i])
+		}
+	}
+
+	if _, err := AnalyzeImage(strings.NewReader("RIFF....WEBPVP8 ")); !errors.Is(err, image.ErrFormat) {
+		t.Errorf("AnalyzeImage returned wrong error for WebP got %v, want %v", err, image.ErrFormat)
+	}
+}
+
+func TestOrientation(t *testing.T) {
+	tests := []struct {
+		w, h int
+		want Orientation
+	}{
+		{1920, 1080, Landscape},
+		{1080, 1920, Portrait},
+		{1000, 1000, Square},
+		{1000, 980, Square},
+		{1000, 900, Landscape},
+	}
+	for _, v := range tests {
+		a := ImageAnalysis{Width: v.w, Height: v.h, AspectRatio: float64(v.w) / float64(v.h)}
+		if got := a.Orientation(); got != v.want {
+			t.Errorf("Orientation of %dx%d returned wrong value got %s, want %s", v.w, v.h, got, v.want)
+		}
+	}
+}
+
+func TestAnalyzeAPOD(t *testing.T) {
+	ts := nasatest.NewServer()
+	c := NewClient(WithBaseURL(ts.URL), WithRetryPolicy(NoRetry), WithCache(NewMemoryCache(10)))
+	apod, err := c.APODDate(FirstAPODDate)
+	if err != nil {
+		t.Fatal(err)
+	}
+	a, err := c.AnalyzeAPODContext(context.Background(), *apod)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if a.Width != 1280 || a.Height != 960 || len(a.Palette) == 0 || a.Luminance == 0 {
+		t.Errorf("AnalyzeAPOD returned wrong analysis got %s, want the 1280x960 HD picture", a)
+	}
+
+	// the analysis is cached, the picture is not downloaded again
+	ts.Close()
+	cached, err := c.AnalyzeAPOD(*apod)
+	if err != nil {
+		t.Fatalf("AnalyzeAPOD did not use the cached analysis: %v", err)
+	}
+	if cached.String() != a.String() {
+		t.Errorf("AnalyzeAPOD returned wrong cached analysis got %s, want %s", cached, a)
+	}
+	if _, err := c.AnalyzeAPOD(*apod, DownloadSD()); err == nil {
+		t.Errorf("AnalyzeAPOD returned a cached analysis for the SD picture")
+	}
+}
+
+func TestMirrorAnalyze(t *testing.T) {
+	ts := nasatest.NewServer()
+	defer ts.Close()
+	c := NewClient(WithBaseURL(ts.URL), WithRetryPolicy(NoRetry))
+	dir := t.TempDir()
+	m, err := c.OpenMirror(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	from := FirstAPODDate
+	if _, err := m.Sync(context.Background(), from, from.AddDays(1), SyncAnalyze(), SyncDownloadOptions(DownloadSD())); err != nil {
+		t.Fatal(err)
+	}
+	if e, _ := m.Entry(from); e.Analysis == nil || e.Analysis.Width != 320 {
+		t.Errorf("Sync did not analyze the picture of %s got %+v", from, e.Analysis)
+	}
+
+	// analyses are saved in the index
+	day := time.Date(1995, 6, 20, 0, 0, 0, 0, time.UTC)
+	if _, err := m.Sync(context.Background(), DateOf(day), DateOf(day)); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := m.Analyze(DateOf(day)); err != nil {
+		t.Fatal(err)
+	}
+	m, err = c.OpenMirror(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if e, _ := m.Entry(DateOf(day)); e.Analysis == nil || e.Analysis.Width != 1280 {
+		t.Errorf("Analyze did not save the analysis of %s got %+v", DateOf(day), e.Analysis)
+	}
+	if _, err := m.Analyze(from.AddDays(-1)); err == nil {
+		t.Errorf("Analyze returned no error for a date not in the mirror")
+	}
+}
@@ -1,14 +1,12 @@
 package nasa
 
 import (
-	"encoding/json"
-	"errors"
+	"context"
 	"fmt"
-	"io/ioutil"
-	"math/rand"
-	"net/http"
 	"net/url"
 	"os"
+	"sort"
+	"strings"
 	"sync"
 	"time"
 )
@@ -26,52 +24,81 @@ const APIKEYMissing = `Currently using the demo API Key DEMO_KEY. Apply for an A
 Check out https://github.com/peteretelej/nasa/blob/master/README.md for more info.
 `
 
-// APODEndpoint is the NASA API APOD endpoint
+// APODEndpoint is the NASA API APOD endpoint used by DefaultClient
 var APODEndpoint = "https://api.nasa.gov/planetary/apod"
 
+// APOD media types
+const (
+	MediaImage = "image"
+	MediaVideo = "video"
+	MediaOther = "other" // e.g. interactive pages
+)
+
 // Image defines the structure of NASA images
 type Image struct {
-	Date        string `json:"date"`
-	Title       string `json:"title"`
-	URL         string `json:"url"`
-	HDURL       string `json:"hdurl"`
-	Explanation string `json:"explanation"`
+	Date           Date   `json:"date"`
+	Title          string `json:"title"`
+	URL            string `json:"url"`
+	HDURL          string `json:"hdurl"`
+	Explanation    string `json:"explanation"`
+	MediaType      string `json:"media_type"`              // MediaImage, MediaVideo or MediaOther
+	Copyright      string `json:"copyright,omitempty"`     // credit that must be shown, empty for public domain
+	ThumbnailURL   string `json:"thumbnail_url,omitempty"` // videos only
+	ServiceVersion string `json:"service_version,omitempty"`
 
+	// Deprecated: ApodDate is Date at midnight UTC, use Date.
 	ApodDate time.Time `json:",omitempty"`
 }
 
+// IsVideo reports whether the APOD is a video rather than an image
+func (ni Image) IsVideo() bool { return ni.MediaType == MediaVideo }
+
+// Credit returns the copyright credit to display with the APOD
+func (ni Image) Credit() string {
+	if ni.Copyright == "" {
+		return "Public Domain"
+	}
+	return "Copyright: " + strings.Join(strings.Fields(ni.Copyright), " ")
+}
+
+// apodPageBase is the url of the directory of APOD pages on apod.nasa.gov
+const apodPageBase = "https://apod.nasa.gov/apod/"
+
+// PageURL returns the url of the APOD's page on apod.nasa.gov
+func (ni Image) PageURL() string {
+	return apodPageBase + apodPageName(ni.Date)
+}
+
+// apodPageName returns the file name of the page of the APOD of d e.g. ap170513.html
+func apodPageName(d Date) string {
+	return fmt.Sprintf("ap%02d%02d%02d.html", d.Year%100, d.Month, d.Day)
+}
+
 func (ni Image) String() string {
+	media := fmt.Sprintf("Image: %s\nHD Image: %s", ni.URL, ni.HDURL)
+	if ni.IsVideo() {
+		media = fmt.Sprintf("Video: %s\nThumbnail: %s", ni.URL, ni.ThumbnailURL)
+	}
+	credit := ni.Credit()
+	if objects := ni.ParsedExplanation().Objects; len(objects) > 0 {
+		credit += "\nObjects: " + strings.Join(objects, ", ")
+	}
 	return fmt.Sprintf(`Title: %s
 Date: %s
-Image: %s
-HD Image: %s
+%s
+%s
 About:
 %s
-`, ni.Title, ni.Date, ni.URL, ni.HDURL, ni.Explanation)
-}
-
-func init() {
-	rand.Seed(time.Now().UnixNano())
-}
-
-// RandomAPOD returns an Astronomy Picture of the Day based on a random date
-// Picks any image shared between the last 2 years
-func RandomAPOD() (*Image, error) {
-	days := 2 * 365 // Any day in last 2 years
-	randDaysOld := time.Duration(rand.Intn(days))
-	t := time.Now().Add(-(time.Hour * 24 * randDaysOld))
-	return ApodImage(t)
+`, ni.Title, ni.Date, media, credit, ni.Explanation)
 }
 
 // caches todays APOD
 type todaysAPOD struct {
 	mu   sync.RWMutex // protects the following
-	date string       // YYYY-MM-DD
+	date Date
 	apod *Image
 }
 
-var tAPOD = &todaysAPOD{}
-
 func (v *todaysAPOD) update(apod Image) {
 	v.mu.Lock()
 	v.apod = &apod
@@ -79,66 +106,170 @@ func (v *todaysAPOD) update(apod Image) {
 	v.mu.Unlock()
 }
 
-//APODToday returns today's APOD, from cache if possible, fetches fresh if not
+// APODToday returns today's APOD, from cache if possible, fetches fresh if not
 func APODToday() (*Image, error) {
-	d := time.Now().Format("2006-01-02")
+	return DefaultClient.APODTodayContext(context.Background())
+}
+
+// APODTodayContext is like APODToday but uses ctx for the API request
+func APODTodayContext(ctx context.Context) (*Image, error) {
+	return DefaultClient.APODTodayContext(ctx)
+}
 
-	tAPOD.mu.RLock()
-	cacheddate, apod := tAPOD.date, tAPOD.apod
-	tAPOD.mu.RUnlock()
+// APODToday returns today's APOD, from cache if possible, fetches fresh if not
+func (c *Client) APODToday() (*Image, error) {
+	return c.APODTodayContext(context.Background())
+}
+
+// APODTodayContext is like APODToday but uses ctx for the API request
+func (c *Client) APODTodayContext(ctx context.Context) (*Image, error) {
+	d := LatestAPODDate()
+
+	c.today.mu.RLock()
+	cacheddate, apod := c.today.date, c.today.apod
+	c.today.mu.RUnlock()
 
 	if cacheddate != d || apod == nil {
-		return ApodImage(time.Now())
+		return c.APODDateContext(ctx, d)
 	}
 	return apod, nil
 }
 
 // ApodImage returns the NASA Astronomy Picture of the Day
 func ApodImage(t time.Time) (*Image, error) {
-	var today bool
-	if t.After(time.Now()) {
-		t = time.Now()
-	}
-	date := t.Format("2006-01-02")
-	today = time.Now().Format("2006-01-02") == date
-	u, err := url.Parse(APODEndpoint)
-	if err != nil {
+	return DefaultClient.APODContext(context.Background(), t)
+}
+
+// ApodImageContext is like ApodImage but uses ctx for the API request
+func ApodImageContext(ctx context.Context, t time.Time) (*Image, error) {
+	return DefaultClient.APODContext(ctx, t)
+}
+
+// APODDate returns the APOD of date d, an error matching ErrDateOutOfRange
+// if d is before the first APOD or after the latest published one
+func APODDate(d Date) (*Image, error) {
+	return DefaultClient.APODDateContext(context.Background(), d)
+}
+
+// APODDateContext is like APODDate but uses ctx for the API request
+func APODDateContext(ctx context.Context, d Date) (*Image, error) {
+	return DefaultClient.APODDateContext(ctx, d)
+}
+
+// APOD returns the NASA Astronomy Picture of the Day for the date of t in t's location.
+// Dates after the latest published APOD return the latest one.
+func (c *Client) APOD(t time.Time) (*Image, error) {
+	return c.APODContext(context.Background(), t)
+}
+
+// APODContext is like APOD but uses ctx for the API request
+func (c *Client) APODContext(ctx context.Context, t time.Time) (*Image, error) {
+	d := DateOf(t)
+	if latest := LatestAPODDate(); d.After(latest) {
+		d = latest
+	}
+	return c.APODDateContext(ctx, d)
+}
+
+// APODDate returns the APOD of date d, an error matching ErrDateOutOfRange
+// if d is before the first APOD or after the latest published one
+func (c *Client) APODDate(d Date) (*Image, error) {
+	return c.APODDateContext(context.Background(), d)
+}
+
+// APODDateContext is like APODDate but uses ctx for the API request
+func (c *Client) APODDateContext(ctx context.Context, d Date) (*Image, error) {
+	if err := ValidateAPODDate(d); err != nil {
 		return nil, err
 	}
-	q := u.Query()
-	q.Set("api_key", nasaKey)
+	today := d == LatestAPODDate()
+	// the date is always sent, so cached responses of today's APOD are keyed by its date
+	// and not served as the APOD of the next day
+	q := url.Values{}
+	q.Set("date", d.String())
+	q.Set("thumbs", "true")
+	ttl := CacheTTLRecent // today's APOD may still be updated
 	if !today {
-		q.Add("date", date)
+		ttl = 0
 	}
-	u.RawQuery = q.Encode()
-	req, err := http.NewRequest("GET", u.String(), nil)
-	if err != nil {
+	var ni Image
+	if err := c.get(ctx, EndpointAPOD, c.apodURL(), q, ttl, &ni); err != nil {
 		return nil, err
 	}
-	client := &http.Client{Timeout: time.Second * 20}
-	resp, err := client.Do(req)
-	if err != nil {
-		return nil, fmt.Errorf("unable to connect to NASA API, %v", err)
+	if ni.URL == "" && ni.HDURL == "" && ni.MediaType != MediaOther {
+		return nil, fmt.Errorf("NASA APOD API returned an invalid response, may be down temporarily: %w", ErrUpstreamDown)
 	}
-	defer func() { _ = resp.Body.Close() }()
-	dat, err := ioutil.ReadAll(resp.Body)
-	if err != nil {
-		return nil, err
+	ni.setApodDate()
+	if today {
+		c.today.update(ni)
 	}
-	_ = resp.Body.Close()
-	var ni Image
-	err = json.Unmarshal(dat, &ni)
-	if err != nil {
-		return nil, err
+	return &ni, nil
+}
+
+// setApodDate sets the deprecated ApodDate field from Date
+func (ni *Image) setApodDate() {
+	if !ni.Date.IsZero() {
+		ni.ApodDate = ni.Date.Time(time.UTC)
+	}
+}
+
+// apodRangeLimit is the maximum number of days APODRange requests at once
+const apodRangeLimit = 100
+
+// APODRange returns the APODs from start to end, inclusive, ordered by date.
+// Long ranges are split into several requests, dates after the latest APOD are left out.
+func APODRange(start, end time.Time) ([]Image, error) {
+	return DefaultClient.APODRangeContext(context.Background(), start, end)
+}
+
+// APODRangeContext is like APODRange but uses ctx for the API requests
+func APODRangeContext(ctx context.Context, start, end time.Time) ([]Image, error) {
+	return DefaultClient.APODRangeContext(ctx, start, end)
+}
+
+// APODRange returns the APODs from start to end, inclusive, ordered by date.
+// Long ranges are split into several requests, dates after the latest APOD are left out.
+func (c *Client) APODRange(start, end time.Time) ([]Image, error) {
+	return c.APODRangeContext(context.Background(), start, end)
+}
+
+// APODRangeContext is like APODRange but uses ctx for the API requests
+func (c *Client) APODRangeContext(ctx context.Context, start, end time.Time) ([]Image, error) {
+	from, to := DateOf(start), DateOf(end)
+	latest := LatestAPODDate()
+	if to.After(latest) {
+		to = latest
 	}
-	if ni.URL == "" && ni.HDURL == "" {
-		return nil, errors.New("NASA APOD API is returned an invalid response, may be down temporarily")
+	if to.Before(from) {
+		return nil, fmt.Errorf("invalid APOD range, %s is after %s", from, to)
 	}
-	if t, err := time.Parse("2006-01-02", ni.Date); err == nil {
-		ni.ApodDate = t
+	if from.Before(FirstAPODDate) {
+		return nil, ValidateAPODDate(from)
 	}
-	if today {
-		tAPOD.update(ni)
+
+	var apods []Image
+	for cstart := from; !cstart.After(to); cstart = cstart.AddDays(apodRangeLimit) {
+		cend := cstart.AddDays(apodRangeLimit - 1)
+		if cend.After(to) {
+			cend = to
+		}
+		q := url.Values{}
+		q.Set("thumbs", "true")
+		q.Add("start_date", cstart.String())
+		q.Add("end_date", cend.String())
+		var ttl time.Duration
+		if cend == latest {
+			ttl = CacheTTLRecent
+		}
+		var chunk []Image
+		if err := c.get(ctx, EndpointAPOD, c.apodURL(), q, ttl, &chunk); err != nil {
+			return nil, err
+		}
+		apods = append(apods, chunk...)
 	}
-	return &ni, nil
+	for i := range apods {
+		apods[i].setApodDate()
+	}
+	sort.Slice(apods, func(i, j int) bool { return apods[i].Date.Before(apods[j].Date) })
+	return apods, nil
 }
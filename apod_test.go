@@ -1,8 +1,12 @@
 package nasa
 
 import (
+	"errors"
 	"strings"
 	"testing"
+	"time"
+
+	"github.com/peteretelej/nasa/nasatest"
 )
 
 func TestRandomAPOD(t *testing.T) {
@@ -18,3 +22,77 @@ func TestRandomAPOD(t *testing.T) {
 	}
 
 }
+
+func TestApodImageFailures(t *testing.T) {
+	c := NewClient(WithRetryPolicy(NoRetry))
+	day := time.Date(2017, 5, 11, 0, 0, 0, 0, time.UTC)
+	tests := []struct {
+		f   nasatest.Failure
+		err error
+	}{
+		{nasatest.EmptyURL, ErrUpstreamDown},
+		{nasatest.ServerError, ErrUpstreamDown},
+		{nasatest.RateLimited, ErrRateLimited},
+		{nasatest.InvalidKey, ErrInvalidKey},
+	}
+	for _, v := range tests {
+		fakeAPI.Fail(v.f, 1)
+		if _, err := c.APOD(day); !errors.Is(err, v.err) {
+			t.Errorf("APOD returned wrong error for failure %d got %v, want %v", v.f, err, v.err)
+		}
+	}
+	fakeAPI.Fail(nasatest.MalformedJSON, 1)
+	if _, err := c.APOD(day); err == nil {
+		t.Errorf("APOD returned no error for malformed JSON")
+	}
+}
+
+func TestAPODRange(t *testing.T) {
+	c := NewClient()
+	start := time.Date(2016, 12, 1, 0, 0, 0, 0, time.UTC)
+	end := start.AddDate(0, 0, apodRangeLimit+49) // needs 2 requests
+	before := fakeAPI.Requests()
+	apods, err := c.APODRange(start, end)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := fakeAPI.Requests() - before; got != 2 {
+		t.Errorf("APODRange made wrong number of requests got %d, want %d", got, 2)
+	}
+	if len(apods) != apodRangeLimit+50 {
+		t.Fatalf("APODRange returned wrong number of APODs got %d, want %d", len(apods), apodRangeLimit+50)
+	}
+	for i, apod := range apods {
+		want := DateOf(start).AddDays(i)
+		if apod.Date != want || !apod.ApodDate.Equal(want.Time(time.UTC)) {
+			t.Errorf("APODRange returned APOD %d out of order got %s, want %s", i, apod.Date, want)
+			break
+		}
+	}
+	if _, err := c.APODRange(end, start); err == nil {
+		t.Errorf("APODRange returned no error for an invalid range")
+	}
+	if _, err := c.APODRange(time.Date(1995, 6, 1, 0, 0, 0, 0, time.UTC), end); !errors.Is(err, ErrDateOutOfRange) {
+		t.Errorf("APODRange returned wrong error for a range before the first APOD got %v, want %v", err, ErrDateOutOfRange)
+	}
+}
+
+func TestImageString(t *testing.T) {
+	apod, err := ApodImage(time.Date(2017, 5, 12, 0, 0, 0, 0, time.UTC))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !apod.IsVideo() || apod.ThumbnailURL == "" {
+		t.Errorf("ApodImage returned a video without media type or thumbnail: %+v", apod)
+	}
+	if !strings.Contains(apod.String(), "Video:") {
+		t.Errorf("Image Stringer does not show videos")
+	}
+	apod, err = ApodImage(time.Date(2017, 5, 13, 0, 0, 0, 0, time.UTC))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(apod.String(), "Copyright: Mark Hanson") {
+		t.Errorf("Image Stringer does not show the copyright credit")
+	}
+}
@@ -0,0 +1,100 @@
+package nasa
+
+import (
+	"container/list"
+	"sync"
+	"time"
+)
+
+// Cache stores NASA API responses. Keys are the request URL without the API key.
+// Implementations must be safe for concurrent use.
+type Cache interface {
+	// Get returns the value stored for key, ok is false if there's none or it has expired
+	Get(key string) (value []byte, ok bool)
+	// Set stores value for key, a ttl of 0 means the value never expires
+	Set(key string, value []byte, ttl time.Duration)
+}
+
+// CacheTTLRecent is how long responses that may still change are cached,
+// e.g. today's APOD or the NEO feed for the current week.
+// Responses for past dates are immutable and cached without expiry.
+var CacheTTLRecent = time.Hour
+
+// WithCache caches the client's API responses in cache
+func WithCache(cache Cache) Option {
+	return func(c *Client) { c.cache = cache }
+}
+
+// MemoryCache is an in-memory least recently used Cache
+type MemoryCache struct {
+	size int
+
+	mu      sync.Mutex // protects the following
+	entries map[string]*list.Element
+	lru     *list.List // most recently used at the front
+}
+
+type memoryEntry struct {
+	key     string
+	value   []byte
+	expires time.Time // zero if the entry never expires
+}
+
+// NewMemoryCache returns a MemoryCache holding at most size entries
+func NewMemoryCache(size int) *MemoryCache {
+	if size < 1 {
+		size = 1
+	}
+	return &MemoryCache{
+		size:    size,
+		entries: make(map[string]*list.Element),
+		lru:     list.New(),
+	}
+}
+
+// Get returns the value stored for key
+func (mc *MemoryCache) Get(key string) ([]byte, bool) {
+	mc.mu.Lock()
+	defer mc.mu.Unlock()
+	el, ok := mc.entries[key]
+	if !ok {
+		return nil, false
+	}
+	e := el.Value.(*memoryEntry)
+	if !e.expires.IsZero() && time.Now().After(e.expires) {
+		mc.lru.Remove(el)
+		delete(mc.entries, key)
+		return nil, false
+	}
+	mc.lru.MoveToFront(el)
+	return e.value, true
+}
+
+// Set stores value for key, evicting the least recently used entry if the cache is full
+func (mc *MemoryCache) Set(key string, value []byte, ttl time.Duration) {
+	var expires time.Time
+	if ttl > 0 {
+		expires = time.Now().Add(ttl)
+	}
+	mc.mu.Lock()
+	defer mc.mu.Unlock()
+	if el, ok := mc.entries[key]; ok {
+		e := el.Value.(*memoryEntry)
+		e.value, e.expires = value, expires
+		mc.lru.MoveToFront(el)
+		return
+	}
+	mc.entries[key] = mc.lru.PushFront(&memoryEntry{key: key, value: value, expires: expires})
+	for mc.lru.Len() > mc.size {
+		el := mc.lru.Back()
+		mc.lru.Remove(el)
+		delete(mc.entries, el.Value.(*memoryEntry).key)
+	}
+}
+
+// Len returns the number of entries in the cache
+func (mc *MemoryCache) Len() int {
+	mc.mu.Lock()
+	defer mc.mu.Unlock()
+	return mc.lru.Len()
+}
@@ -0,0 +1,80 @@
+package nasa
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// DiskCache is a Cache persisted to a directory, one file per entry.
+// It survives restarts, e.g. for kiosks that repeatedly request the same dates.
+type DiskCache struct {
+	dir string
+}
+
+type diskEntry struct {
+	Key     string    `json:"key"`
+	Expires time.Time `json:"expires,omitempty"`
+	Value   []byte    `json:"value"`
+}
+
+// NewDiskCache returns a DiskCache storing entries in dir, creating it if necessary
+func NewDiskCache(dir string) (*DiskCache, error) {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, err
+	}
+	return &DiskCache{dir: dir}, nil
+}
+
+func (dc *DiskCache) path(key string) string {
+	sum := sha256.Sum256([]byte(key))
+	return filepath.Join(dc.dir, hex.EncodeToString(sum[:])+".json")
+}
+
+// Get returns the value stored for key, expired entries are removed
+func (dc *DiskCache) Get(key string) ([]byte, bool) {
+	p := dc.path(key)
+	dat, err := os.ReadFile(p)
+	if err != nil {
+		return nil, false
+	}
+	var e diskEntry
+	if err := json.Unmarshal(dat, &e); err != nil || e.Key != key {
+		return nil, false
+	}
+	if !e.Expires.IsZero() && time.Now().After(e.Expires) {
+		_ = os.Remove(p)
+		return nil, false
+	}
+	return e.Value, true
+}
+
+// Set stores value for key. Errors writing to disk are ignored, the entry is just not cached.
+func (dc *DiskCache) Set(key string, value []byte, ttl time.Duration) {
+	e := diskEntry{Key: key, Value: value}
+	if ttl > 0 {
+		e.Expires = time.Now().Add(ttl)
+	}
+	dat, err := json.Marshal(e)
+	if err != nil {
+		return
+	}
+	// write to a tempfile then rename, so concurrent readers never see partial entries
+	tmp, err := os.CreateTemp(dc.dir, ".tmp-*")
+	if err != nil {
+		return
+	}
+	_, err = tmp.Write(dat)
+	if cerr := tmp.Close(); err == nil {
+		err = cerr
+	}
+	if err == nil {
+		err = os.Rename(tmp.Name(), dc.path(key))
+	}
+	if err != nil {
+		_ = os.Remove(tmp.Name())
+	}
+}
@@ -0,0 +1,110 @@
+package nasa
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func testCache(t *testing.T, name string, c Cache) {
+	c.Set("a", []byte("A"), 0)
+	c.Set("expired", []byte("E"), time.Nanosecond)
+	time.Sleep(time.Millisecond)
+
+	if v, ok := c.Get("a"); !ok || string(v) != "A" {
+		t.Errorf("%s.Get returned wrong value got %q, want %q", name, v, "A")
+	}
+	if _, ok := c.Get("expired"); ok {
+		t.Errorf("%s.Get returned an expired value", name)
+	}
+	if _, ok := c.Get("missing"); ok {
+		t.Errorf("%s.Get returned a value for a missing key", name)
+	}
+	c.Set("a", []byte("B"), time.Hour)
+	if v, ok := c.Get("a"); !ok || string(v) != "B" {
+		t.Errorf("%s.Get returned wrong value after update got %q, want %q", name, v, "B")
+	}
+}
+
+func TestMemoryCache(t *testing.T) {
+	testCache(t, "MemoryCache", NewMemoryCache(10))
+
+	mc := NewMemoryCache(2)
+	mc.Set("a", []byte("A"), 0)
+	mc.Set("b", []byte("B"), 0)
+	mc.Get("a") // b is now the least recently used
+	mc.Set("c", []byte("C"), 0)
+	if _, ok := mc.Get("b"); ok {
+		t.Errorf("MemoryCache did not evict the least recently used entry")
+	}
+	if _, ok := mc.Get("a"); !ok {
+		t.Errorf("MemoryCache evicted a recently used entry")
+	}
+	if mc.Len() != 2 {
+		t.Errorf("MemoryCache has wrong length got %d, want %d", mc.Len(), 2)
+	}
+}
+
+func TestDiskCache(t *testing.T) {
+	dir := t.TempDir()
+	dc, err := NewDiskCache(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	testCache(t, "DiskCache", dc)
+
+	// entries persist across instances
+	dc2, err := NewDiskCache(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if v, ok := dc2.Get("a"); !ok || string(v) != "B" {
+		t.Errorf("DiskCache did not persist entry got %q, want %q", v, "B")
+	}
+}
+
+func TestClientCache(t *testing.T) {
+	var calls int
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		fmt.Fprintf(w, `{"date":"%s","title":"Test","url":"https://apod.nasa.gov/a.jpg"}`, r.URL.Query().Get("date"))
+	}))
+	defer ts.Close()
+
+	cache := NewMemoryCache(10)
+	day := time.Date(2017, 5, 11, 0, 0, 0, 0, time.UTC)
+	for _, key := range []string{"KEY1", "KEY2"} {
+		c := NewClient(WithBaseURL(ts.URL), WithAPIKey(key), WithCache(cache))
+		for i := 0; i < 2; i++ {
+			apod, err := c.APOD(day)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if apod.Date.String() != "2017-05-11" {
+				t.Errorf("APOD returned wrong date from cache got %s, want %s", apod.Date, "2017-05-11")
+			}
+		}
+	}
+	if calls != 1 {
+		t.Errorf("APOD with cache made wrong number of requests got %d, want %d", calls, 1)
+	}
+}
+
+func TestClientCacheToday(t *testing.T) {
+	var keys []string
+	c := NewClient(WithCache(NewMemoryCache(10)), WithHooks(Hooks{
+		OnCache: func(_ context.Context, info CacheInfo) { keys = append(keys, info.Key) },
+	}))
+	latest := LatestAPODDate()
+	if _, err := c.APODDate(latest); err != nil {
+		t.Fatal(err)
+	}
+	// keyed by the date, the cached APOD is not served as the next day's after midnight
+	if len(keys) != 1 || !strings.Contains(keys[0], "date="+latest.String()) {
+		t.Errorf("APODDate cached today's APOD with wrong key got %q, want one with date=%s", keys, latest)
+	}
+}
@@ -0,0 +1,157 @@
+package nasa
+
+import (
+	"fmt"
+	"image"
+	"image/color"
+	"image/draw"
+	"strings"
+)
+
+// CaptionPosition is where a caption is drawn on a picture
+type CaptionPosition string
+
+// Caption positions
+const (
+	CaptionBottomLeft  CaptionPosition = "bottom-left"
+	CaptionBottomRight CaptionPosition = "bottom-right"
+	CaptionTopLeft     CaptionPosition = "top-left"
+	CaptionTopRight    CaptionPosition = "top-right"
+)
+
+// ParseCaptionPosition returns the CaptionPosition named s
+func ParseCaptionPosition(s string) (CaptionPosition, error) {
+	switch p := CaptionPosition(s); p {
+	case CaptionBottomLeft, CaptionBottomRight, CaptionTopLeft, CaptionTopRight:
+		return p, nil
+	}
+	return "", fmt.Errorf("invalid caption position %q, should be bottom-left, bottom-right, top-left or top-right", s)
+}
+
+// DefaultCaptionBackdrop is the opacity of the backdrop behind captions unless CaptionBackdrop is set
+const DefaultCaptionBackdrop = 0.5
+
+// CaptionOption configures a caption
+type CaptionOption func(*captionOptions)
+
+type captionOptions struct {
+	position CaptionPosition
+	size     int
+	backdrop float64
+}
+
+// CaptionAt sets where the caption is drawn, bottom left by default
+func CaptionAt(p CaptionPosition) CaptionOption {
+	return func(o *captionOptions) { o.position = p }
+}
+
+// CaptionSize sets the height of a line of the caption in pixels. By default it's
+// a 40th of the picture's height. The font is a bitmap font, scaled by whole pixels.
+func CaptionSize(px int) CaptionOption {
+	return func(o *captionOptions) { o.size = px }
+}
+
+// CaptionBackdrop sets the opacity of the black backdrop behind the caption, from 0 for none to 1
+func CaptionBackdrop(opacity float64) CaptionOption {
+	return func(o *captionOptions) { o.backdrop = opacity }
+}
+
+// ProcessCaption draws the title, date and credit of the APOD onto the picture,
+// after fitting it to a size if ProcessFit is set
+func ProcessCaption(apod Image, opts ...CaptionOption) ProcessOption {
+	return func(o *processOptions) {
+		o.caption = CaptionLines(apod)
+		o.captionOpts = opts
+	}
+}
+
+// CaptionLines returns the lines of the caption of an APOD: its title, then its
+// date and credit
+func CaptionLines(apod Image) []string {
+	return []string{
+		strings.Join(strings.Fields(apod.Title), " "),
+		apod.Date.String() + " - " + apod.Credit(),
+	}
+}
+
+// DrawCaption draws lines of text onto img in a bitmap font, over a backdrop.
+// Characters outside of printable ASCII are spelled in ASCII where possible and
+// lines too wide for the picture are wrapped.
+func DrawCaption(img draw.Image, lines []string, opts ...CaptionOption) {
+	o := captionOptions{position: CaptionBottomLeft, backdrop: DefaultCaptionBackdrop}
+	for _, opt := range opts {
+		opt(&o)
+	}
+	b := img.Bounds()
+	size := o.size
+	if size <= 0 {
+		size = b.Dy() / 40
+	}
+	scale := max(1, size/glyphHeight)
+	margin := 2 * glyphWidth * scale
+	maxChars := max(1, (b.Dx()-4*margin)/(glyphWidth*scale))
+
+	var text []string
+	for _, l := range lines {
+		text = append(text, wrap(fontText(l), maxChars)...)
+	}
+	if len(text) == 0 {
+		return
+	}
+	width := 0
+	for _, l := range text {
+		width = max(width, textWidth(l, scale))
+	}
+	pad := glyphWidth * scale
+	box := image.Rect(0, 0, width+2*pad, len(text)*glyphHeight*scale+2*pad-2*scale)
+	switch o.position {
+	case CaptionTopLeft:
+		box = box.Add(image.Pt(b.Min.X+margin, b.Min.Y+margin))
+	case CaptionTopRight:
+		box = box.Add(image.Pt(b.Max.X-margin-box.Dx(), b.Min.Y+margin))
+	case CaptionBottomRight:
+		box = box.Add(image.Pt(b.Max.X-margin-box.Dx(), b.Max.Y-margin-box.Dy()))
+	default:
+		box = box.Add(image.Pt(b.Min.X+margin, b.Max.Y-margin-box.Dy()))
+	}
+	if o.backdrop > 0 {
+		a := uint8(min(o.backdrop, 1) * 255)
+		draw.Draw(img, box, image.NewUniform(color.RGBA{0, 0, 0, a}), image.Point{}, draw.Over)
+	}
+	for i, l := range text {
+		x := box.Min.X + pad
+		if o.position == CaptionTopRight || o.position == CaptionBottomRight {
+			x = box.Max.X - pad - textWidth(l, scale)
+		}
+		drawText(img, image.Pt(x, box.Min.Y+pad+i*glyphHeight*scale), l, scale, color.White)
+	}
+}
+
+// wrap splits s into lines of at most n characters, at spaces where possible
+func wrap(s string, n int) []string {
+	var lines []string
+	line := ""
+	for _, word := range strings.Fields(s) {
+		for len(word) > n { // too long for a line of its own
+			if line != "" {
+				lines = append(lines, line)
+				line = ""
+			}
+			lines = append(lines, word[:n])
+			word = word[n:]
+		}
+		switch {
+		case line == "":
+			line = word
+		case len(line)+1+len(word) <= n:
+			line += " " + word
+		default:
+			lines = append(lines, line)
+			line = word
+		}
+	}
+	if line != "" {
+		lines = append(lines, line)
+	}
+	return lines
+}
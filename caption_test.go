@@ -0,0 +1,94 @@
+package nasa
+
+import (
+	"image"
+	"image/color"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestWrap(t *testing.T) {
+	tests := []struct {
+		s    string
+		n    int
+		want []string
+	}{
+		{"The Horsehead Nebula", 30, []string{"The Horsehead Nebula"}},
+		{"The Horsehead Nebula", 13, []string{"The Horsehead", "Nebula"}},
+		{"NGC 6888: The Crescent Nebula", 8, []string{"NGC", "6888:", "The", "Crescent", "Nebula"}},
+		{"Supercalifragilistic", 8, []string{"Supercal", "ifragili", "stic"}},
+		{"  ", 8, nil},
+	}
+	for _, v := range tests {
+		if got := wrap(v.s, v.n); !reflect.DeepEqual(got, v.want) {
+			t.Errorf("wrap(%q, %d) returned wrong lines got %q, want %q", v.s, v.n, got, v.want)
+		}
+	}
+}
+
+func TestFontText(t *testing.T) {
+	tests := []struct{ s, want string }{
+		{"M104: The Sombrero Galaxy", "M104: The Sombrero Galaxy"},
+		{"Comet Hale–Bopp’s Tails", "Comet Hale-Bopp's Tails"},
+		{"Jean-Charles Cuillandre (CFHT), Hawaiian Starlight,\nCFHT", "Jean-Charles Cuillandre (CFHT), Hawaiian Starlight, CFHT"},
+		{"Ángel Gómez", "Angel Gomez"},
+		{"星空", "??"},
+	}
+	for _, v := range tests {
+		if got := fontText(v.s); got != v.want {
+			t.Errorf("fontText(%q) returned wrong text got %q, want %q", v.s, got, v.want)
+		}
+	}
+}
+
+// whiteIn returns the number of white pixels of img in r
+func whiteIn(img *image.RGBA, r image.Rectangle) int {
+	n := 0
+	for y := r.Min.Y; y < r.Max.Y; y++ {
+		for x := r.Min.X; x < r.Max.X; x++ {
+			if img.RGBAAt(x, y) == (color.RGBA{255, 255, 255, 255}) {
+				n++
+			}
+		}
+	}
+	return n
+}
+
+func TestDrawCaption(t *testing.T) {
+	apod := Image{Date: Date{2013, time.November, 8}, Title: "The Horsehead Nebula", Copyright: "Ken Crawford"}
+	want := []string{"The Horsehead Nebula", "2013-11-08 - Copyright: Ken Crawford"}
+	if got := CaptionLines(apod); !reflect.DeepEqual(got, want) {
+		t.Errorf("CaptionLines returned wrong lines got %q, want %q", got, want)
+	}
+
+	tests := []struct {
+		pos  CaptionPosition
+		want image.Rectangle // quadrant the caption is in
+	}{
+		{CaptionBottomLeft, image.Rect(0, 300, 400, 600)},
+		{CaptionBottomRight, image.Rect(400, 300, 800, 600)},
+		{CaptionTopLeft, image.Rect(0, 0, 400, 300)},
+		{CaptionTopRight, image.Rect(400, 0, 800, 300)},
+	}
+	for _, v := range tests {
+		img := image.NewRGBA(image.Rect(0, 0, 800, 600))
+		for i := range img.Pix {
+			img.Pix[i] = 100
+		}
+		DrawCaption(img, []string{"Horsehead"}, CaptionAt(v.pos), CaptionSize(18), CaptionBackdrop(1))
+		if n := whiteIn(img, v.want); n == 0 || n != whiteIn(img, img.Bounds()) {
+			t.Errorf("DrawCaption at %s drew %d of %d white pixels in %v", v.pos, n, whiteIn(img, img.Bounds()), v.want)
+		}
+		if c := img.RGBAAt(v.want.Min.X+v.want.Dx()/2, v.want.Min.Y+v.want.Dy()/2); c.R != 100 {
+			t.Errorf("DrawCaption at %s drew over the middle of the picture", v.pos)
+		}
+	}
+
+	// processing doesn't draw on the image processed
+	src := image.NewRGBA(image.Rect(0, 0, 400, 300))
+	dst := Process(src, ProcessCaption(apod)).(*image.RGBA)
+	if whiteIn(src, src.Bounds()) != 0 || whiteIn(dst, dst.Bounds()) == 0 {
+		t.Errorf("Process with a caption drew on the wrong image")
+	}
+}
@@ -0,0 +1,228 @@
+// Package cassette records NASA API interactions to cassette files and replays
+// them, e.g. for deterministic tests and offline demos.
+//
+// Example Usage
+//
+//	rec, err := cassette.NewRecorder("testdata/apod.json", cassette.ReplayOrRecord)
+//	if err != nil {
+//		return err
+//	}
+//	defer rec.Save()
+//	c := nasa.NewClient(nasa.WithHTTPClient(&http.Client{Transport: rec}))
+package cassette
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"regexp"
+	"sync"
+	"unicode/utf8"
+)
+
+// Mode defines whether a Recorder replays or records interactions
+type Mode int
+
+// Recorder modes
+const (
+	Replay         Mode = iota // replay recorded interactions, fail on unrecorded requests
+	Record                     // send all requests and record the responses
+	ReplayOrRecord             // replay if recorded, otherwise send and record
+)
+
+// Interaction is a recorded request and its response
+type Interaction struct {
+	Method     string      `json:"method"`
+	URL        string      `json:"url"` // without the api_key query parameter
+	StatusCode int         `json:"status"`
+	Header     http.Header `json:"header,omitempty"`
+	Body       string      `json:"body,omitempty"`        // text bodies
+	BodyBase64 []byte      `json:"body_base64,omitempty"` // binary bodies e.g. images
+}
+
+func (in Interaction) body() []byte {
+	if in.BodyBase64 != nil {
+		return in.BodyBase64
+	}
+	return []byte(in.Body)
+}
+
+// File is the format of cassette files
+type File struct {
+	Interactions []Interaction `json:"interactions"`
+}
+
+// Recorder is an http.RoundTripper that records NASA API interactions to a
+// cassette file and replays them.
+// API keys are scrubbed from recorded urls, headers and text bodies. Use it as the Transport of the
+// http.Client given to nasa.WithHTTPClient.
+//
+// Identical requests are replayed in the order they were recorded, the last
+// response is repeated once they are used up.
+type Recorder struct {
+	// Transport makes the real requests when recording, defaults to http.DefaultTransport
+	Transport http.RoundTripper
+
+	path string
+	mode Mode
+
+	mu       sync.Mutex // protects the following
+	cassette File
+	replayed map[string]int // interactions replayed per request
+}
+
+// NewRecorder returns a Recorder for the cassette file at path, loading it if it exists.
+// In Replay mode the file must exist.
+func NewRecorder(path string, mode Mode) (*Recorder, error) {
+	r := &Recorder{path: path, mode: mode, replayed: make(map[string]int)}
+	dat, err := os.ReadFile(path)
+	switch {
+	case err == nil:
+		if err := json.Unmarshal(dat, &r.cassette); err != nil {
+			return nil, fmt.Errorf("cassette: invalid cassette %s: %v", path, err)
+		}
+	case os.IsNotExist(err) && mode != Replay:
+	default:
+		return nil, err
+	}
+	if mode == Record {
+		r.cassette = File{}
+	}
+	return r, nil
+}
+
+// Scrub returns rawurl without the api_key query parameter
+func Scrub(rawurl string) string {
+	u, err := url.Parse(rawurl)
+	if err != nil {
+		return rawurl
+	}
+	q := u.Query()
+	if _, ok := q["api_key"]; !ok {
+		return rawurl
+	}
+	q.Del("api_key")
+	u.RawQuery = q.Encode()
+	return u.String()
+}
+
+// apiKeyRE matches api_key query parameters in text, e.g. the links of NeoWs
+// responses. Values end at a backslash too, as & is escaped \u0026 in JSON.
+var apiKeyRE = regexp.MustCompile(`(api_key=)[^&"'\s<>\\]*`)
+
+// scrubText returns s without API key values
+func scrubText(s string) string {
+	return apiKeyRE.ReplaceAllString(s, "${1}")
+}
+
+// RoundTrip replays or records the request
+func (r *Recorder) RoundTrip(req *http.Request) (*http.Response, error) {
+	key := req.Method + " " + Scrub(req.URL.String())
+	if r.mode != Record {
+		if in, ok := r.lookup(key); ok {
+			return in.response(req), nil
+		}
+		if r.mode == Replay {
+			return nil, fmt.Errorf("cassette: no recorded interaction for %s", key)
+		}
+	}
+
+	transport := r.Transport
+	if transport == nil {
+		transport = http.DefaultTransport
+	}
+	resp, err := transport.RoundTrip(req)
+	if err != nil {
+		return nil, err
+	}
+	dat, err := io.ReadAll(resp.Body)
+	_ = resp.Body.Close()
+	if err != nil {
+		return nil, err
+	}
+	resp.Body = io.NopCloser(bytes.NewReader(dat))
+
+	in := Interaction{
+		Method:     req.Method,
+		URL:        Scrub(req.URL.String()),
+		StatusCode: resp.StatusCode,
+		Header:     resp.Header.Clone(),
+	}
+	for _, vs := range in.Header {
+		for i, v := range vs {
+			vs[i] = scrubText(v)
+		}
+	}
+	if utf8.Valid(dat) {
+		in.Body = scrubText(string(dat))
+	} else {
+		in.BodyBase64 = dat
+	}
+	r.mu.Lock()
+	r.cassette.Interactions = append(r.cassette.Interactions, in)
+	r.replayed[key]++
+	r.mu.Unlock()
+	return resp, nil
+}
+
+// lookup returns the next recorded interaction for the request key
+func (r *Recorder) lookup(key string) (Interaction, bool) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	var matches []Interaction
+	for _, in := range r.cassette.Interactions {
+		if in.Method+" "+in.URL == key {
+			matches = append(matches, in)
+		}
+	}
+	if len(matches) == 0 {
+		return Interaction{}, false
+	}
+	n := r.replayed[key]
+	r.replayed[key]++
+	if n >= len(matches) {
+		n = len(matches) - 1
+	}
+	return matches[n], true
+}
+
+func (in Interaction) response(req *http.Request) *http.Response {
+	body := in.body()
+	header := in.Header.Clone()
+	if header == nil {
+		header = make(http.Header)
+	}
+	return &http.Response{
+		Status:        fmt.Sprintf("%d %s", in.StatusCode, http.StatusText(in.StatusCode)),
+		StatusCode:    in.StatusCode,
+		Proto:         "HTTP/1.1",
+		ProtoMajor:    1,
+		ProtoMinor:    1,
+		Header:        header,
+		Body:          io.NopCloser(bytes.NewReader(body)),
+		ContentLength: int64(len(body)),
+		Request:       req,
+	}
+}
+
+// Interactions returns the interactions in the cassette
+func (r *Recorder) Interactions() []Interaction {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return append([]Interaction(nil), r.cassette.Interactions...)
+}
+
+// Save writes the cassette to its file
+func (r *Recorder) Save() error {
+	r.mu.Lock()
+	dat, err := json.MarshalIndent(r.cassette, "", "  ")
+	r.mu.Unlock()
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(r.path, dat, 0644)
+}
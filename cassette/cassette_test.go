@@ -0,0 +1,106 @@
+package cassette
+
+import (
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/peteretelej/nasa/nasatest"
+)
+
+func TestRecorder(t *testing.T) {
+	ts := nasatest.NewServer()
+	path := filepath.Join(t.TempDir(), "cassette.json")
+
+	rec, err := NewRecorder(path, Record)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cl := &http.Client{Transport: rec}
+	apodURL := ts.APODEndpoint() + "?api_key=SECRET&date=2017-05-11"
+	imgURL := ts.URL + nasatest.ImagePath + "2017-05-11.jpg"
+
+	ts.Fail(nasatest.RateLimited, 1)
+	recorded := make([]string, 0, 3)
+	for _, u := range []string{apodURL, apodURL, imgURL} {
+		resp, err := cl.Get(u)
+		if err != nil {
+			t.Fatal(err)
+		}
+		dat, _ := io.ReadAll(resp.Body)
+		_ = resp.Body.Close()
+		recorded = append(recorded, string(dat))
+	}
+	if err := rec.Save(); err != nil {
+		t.Fatal(err)
+	}
+	ts.Close()
+
+	for _, in := range rec.Interactions() {
+		if strings.Contains(in.URL, "SECRET") {
+			t.Errorf("Recorder did not scrub the api key from %s", in.URL)
+		}
+	}
+
+	// replay with the fake server gone
+	rec, err = NewRecorder(path, Replay)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cl = &http.Client{Transport: rec}
+	wantStatus := []int{http.StatusTooManyRequests, http.StatusOK, http.StatusOK}
+	for i, u := range []string{apodURL, apodURL, imgURL} {
+		resp, err := cl.Get(u)
+		if err != nil {
+			t.Fatal(err)
+		}
+		dat, _ := io.ReadAll(resp.Body)
+		_ = resp.Body.Close()
+		if resp.StatusCode != wantStatus[i] {
+			t.Errorf("replay %d returned wrong status got %d, want %d", i, resp.StatusCode, wantStatus[i])
+		}
+		if string(dat) != recorded[i] {
+			t.Errorf("replay %d returned a different body than recorded", i)
+		}
+	}
+	if _, err := cl.Get(ts.APODEndpoint() + "?api_key=SECRET&date=2017-05-12"); err == nil {
+		t.Errorf("Replay returned no error for an unrecorded request")
+	}
+}
+
+func TestRecorderScrubsKeys(t *testing.T) {
+	ts := nasatest.NewServer()
+	defer ts.Close()
+	path := filepath.Join(t.TempDir(), "cassette.json")
+
+	rec, err := NewRecorder(path, Record)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cl := &http.Client{Transport: rec}
+	resp, err := cl.Get(ts.NeoEndpoint() + "?start_date=2017-05-10&end_date=2017-05-12&api_key=SECRET")
+	if err != nil {
+		t.Fatal(err)
+	}
+	dat, _ := io.ReadAll(resp.Body)
+	_ = resp.Body.Close()
+	if !strings.Contains(string(dat), "SECRET") {
+		t.Fatalf("NEO feed does not include the api key in its links, nothing to scrub: %s", dat)
+	}
+	if err := rec.Save(); err != nil {
+		t.Fatal(err)
+	}
+	cassette, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Contains(string(cassette), "SECRET") {
+		t.Errorf("Recorder saved the api key to the cassette: %s", cassette)
+	}
+	if !strings.Contains(string(cassette), "api_key=") {
+		t.Errorf("Recorder removed the api_key parameters of the links: %s", cassette)
+	}
+}
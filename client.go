@@ -0,0 +1,254 @@
+package nasa
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// DefaultUserAgent is the User-Agent header sent to the NASA API when none is set
+const DefaultUserAgent = "github.com/peteretelej/nasa"
+
+// DefaultTimeout is the http timeout used by clients that don't set one
+const DefaultTimeout = 20 * time.Second
+
+// Client is a NASA API client. Create one with NewClient; a Client is safe for
+// concurrent use and should be reused.
+type Client struct {
+	apiKey       string
+	apiKeys      []string
+	pool         keyPool
+	apodEndpoint string
+	neoEndpoint  string
+	pageBase     string // of APOD pages, see apodPageBase
+	userAgent    string
+	timeout      time.Duration
+	httpClient   *http.Client
+	retry        RetryPolicy
+	cache        Cache
+	hooks        []Hooks
+
+	quotaPolicy  QuotaPolicy
+	quotaReserve int
+	quotas       quotaTracker
+
+	today todaysAPOD // caches today's APOD
+}
+
+// Option configures a Client
+type Option func(*Client)
+
+// WithAPIKey sets the NASA API key used by the client.
+// Defaults to the NASAKEY environment variable, or DEMO_KEY if it's not set
+func WithAPIKey(key string) Option {
+	return func(c *Client) { c.apiKey = key }
+}
+
+// WithBaseURL points the client at a different API host, e.g. a proxy or a test server.
+// The APOD and NeoWs paths, and the /apod/ path of APOD pages, are appended to base.
+func WithBaseURL(base string) Option {
+	return func(c *Client) {
+		base = strings.TrimRight(base, "/")
+		c.apodEndpoint = base + "/planetary/apod"
+		c.neoEndpoint = base + "/neo/rest/v1/feed"
+		c.pageBase = base + "/apod/"
+	}
+}
+
+// WithAPODEndpoint overrides the APOD endpoint used by the client
+func WithAPODEndpoint(endpoint string) Option {
+	return func(c *Client) { c.apodEndpoint = endpoint }
+}
+
+// WithNeoEndpoint overrides the NeoWs feed endpoint used by the client
+func WithNeoEndpoint(endpoint string) Option {
+	return func(c *Client) { c.neoEndpoint = endpoint }
+}
+
+// WithHTTPClient sets the http.Client used to make requests
+func WithHTTPClient(hc *http.Client) Option {
+	return func(c *Client) { c.httpClient = hc }
+}
+
+// WithUserAgent sets the User-Agent header sent with every request
+func WithUserAgent(ua string) Option {
+	return func(c *Client) { c.userAgent = ua }
+}
+
+// WithTimeout sets the timeout of requests made by the client.
+// If used together with WithHTTPClient, the http.Client is copied, not modified.
+func WithTimeout(d time.Duration) Option {
+	return func(c *Client) { c.timeout = d }
+}
+
+// NewClient returns a Client configured with the options provided
+func NewClient(opts ...Option) *Client {
+	c := &Client{userAgent: DefaultUserAgent, retry: DefaultRetryPolicy}
+	for _, opt := range opts {
+		opt(c)
+	}
+	switch {
+	case c.httpClient == nil:
+		timeout := c.timeout
+		if timeout <= 0 {
+			timeout = DefaultTimeout
+		}
+		c.httpClient = &http.Client{Timeout: timeout}
+	case c.timeout > 0:
+		hc := *c.httpClient
+		hc.Timeout = c.timeout
+		c.httpClient = &hc
+	}
+	return c
+}
+
+// DefaultClient is the Client used by the package level functions
+// e.g. ApodImage, RandomAPOD and NeoFeed.
+// It uses the NASAKEY environment variable and the APODEndpoint and NeoEndpoint variables.
+var DefaultClient = NewClient()
+
+// HTTPClient returns the http.Client the client makes requests with,
+// e.g. to download images through the same transport
+func (c *Client) HTTPClient() *http.Client {
+	return c.httpClient
+}
+
+// key returns the first api key of the client, falling back to the NASAKEY env variable
+func (c *Client) key() string {
+	return c.keys()[0]
+}
+
+func (c *Client) apodURL() string {
+	if c.apodEndpoint != "" {
+		return c.apodEndpoint
+	}
+	return APODEndpoint
+}
+
+// pageURL returns the url of the page of the APOD of d
+func (c *Client) pageURL(d Date) string {
+	if c.pageBase != "" {
+		return c.pageBase + apodPageName(d)
+	}
+	return apodPageBase + apodPageName(d)
+}
+
+func (c *Client) neoURL() string {
+	if c.neoEndpoint != "" {
+		return c.neoEndpoint
+	}
+	return NeoEndpoint
+}
+
+// get requests endpoint with the query parameters in q and decodes the JSON response to v.
+// Failed requests are retried according to the client's retry policy.
+// Responses are cached for ttl if the client has a cache, a ttl of 0 caches forever
+// and a negative ttl is never cached.
+// name is the endpoint name reported to hooks e.g. EndpointAPOD.
+func (c *Client) get(ctx context.Context, name, endpoint string, q url.Values, ttl time.Duration, v interface{}) error {
+	u, err := url.Parse(endpoint)
+	if err != nil {
+		return fmt.Errorf("unable to parse endpoint %s: %v", endpoint, err)
+	}
+	query := u.Query()
+	for k, vals := range q {
+		for _, val := range vals {
+			query.Add(k, val)
+		}
+	}
+	u.RawQuery = query.Encode()
+	cacheKey := u.String() // never includes the api key
+	if c.cache != nil && ttl >= 0 {
+		dat, ok := c.cache.Get(cacheKey)
+		c.onCache(ctx, CacheInfo{Endpoint: name, Key: cacheKey, Hit: ok})
+		if ok {
+			return json.Unmarshal(dat, v)
+		}
+	}
+
+	info := RequestInfo{Endpoint: name, URL: cacheKey}
+	retry := c.retry
+	retry.OnRetry = func(attempt int, err error, delay time.Duration) {
+		if c.retry.OnRetry != nil {
+			c.retry.OnRetry(attempt, err, delay)
+		}
+		ri := RetryInfo{RequestInfo: info, Err: err, Delay: delay}
+		ri.Attempt = attempt
+		c.onRetry(ctx, ri)
+	}
+
+	var dat []byte
+	err = retry.Do(ctx, func(attempt int) error {
+		// with several keys, move on to the next key if one is rate limited or invalid
+		var err error
+		for i := 0; i < len(c.keys()); i++ {
+			key := c.pickKey()
+			if err = c.checkQuota(ctx, key); err == nil {
+				query.Set("api_key", key)
+				u.RawQuery = query.Encode()
+				info.Attempt = attempt
+				c.beforeRequest(ctx, info)
+				start := time.Now()
+				var status int
+				dat, status, err = c.fetch(ctx, u.String(), cacheKey, key)
+				c.afterResponse(ctx, ResponseInfo{
+					RequestInfo: info,
+					StatusCode:  status,
+					Duration:    time.Since(start),
+					Err:         err,
+				})
+			}
+			if errors.Is(err, ErrInvalidKey) {
+				c.pool.markInvalid(key)
+			}
+			if !errors.Is(err, ErrRateLimited) && !errors.Is(err, ErrInvalidKey) {
+				break
+			}
+		}
+		return err
+	})
+	if err != nil {
+		return err
+	}
+	if err := json.Unmarshal(dat, v); err != nil {
+		return err
+	}
+	if c.cache != nil && ttl >= 0 {
+		c.cache.Set(cacheKey, dat, ttl)
+	}
+	return nil
+}
+
+// fetch makes a single GET request to rawurl and returns the response body and status.
+// Errors report the url as scrubbed, rawurl without its api key.
+func (c *Client) fetch(ctx context.Context, rawurl, scrubbed, key string) ([]byte, int, error) {
+	req, err := http.NewRequestWithContext(ctx, "GET", rawurl, nil)
+	if err != nil {
+		return nil, 0, err
+	}
+	req.Header.Set("User-Agent", c.userAgent)
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		var ue *url.Error
+		if errors.As(err, &ue) {
+			ue.URL = scrubbed
+		}
+		return nil, 0, fmt.Errorf("unable to connect to NASA API, %w", err)
+	}
+	defer func() { _ = resp.Body.Close() }()
+	c.quotas.update(key, resp.StatusCode, resp.Header)
+	dat, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, resp.StatusCode, err
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, resp.StatusCode, newAPIError(resp.StatusCode, resp.Header, dat)
+	}
+	return dat, resp.StatusCode, nil
+}
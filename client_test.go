@@ -0,0 +1,83 @@
+package nasa
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestClientOptions(t *testing.T) {
+	var gotKey, gotUA, gotPath string
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		gotKey = r.URL.Query().Get("api_key")
+		gotUA = r.UserAgent()
+		gotPath = r.URL.Path
+		fmt.Fprint(w, `{"date":"2017-05-11","title":"Test","url":"https://apod.nasa.gov/a.jpg"}`)
+	}))
+	defer ts.Close()
+
+	c := NewClient(
+		WithBaseURL(ts.URL+"/"),
+		WithAPIKey("TEST_KEY"),
+		WithUserAgent("nasa-test"),
+		WithTimeout(5*time.Second),
+	)
+	apod, err := c.APOD(time.Date(2017, 5, 11, 0, 0, 0, 0, time.UTC))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if apod.Title != "Test" {
+		t.Errorf("APOD returned wrong title got %q, want %q", apod.Title, "Test")
+	}
+	if gotKey != "TEST_KEY" {
+		t.Errorf("client sent wrong api key got %q, want %q", gotKey, "TEST_KEY")
+	}
+	if gotUA != "nasa-test" {
+		t.Errorf("client sent wrong user agent got %q, want %q", gotUA, "nasa-test")
+	}
+	if gotPath != "/planetary/apod" {
+		t.Errorf("client requested wrong path got %q, want %q", gotPath, "/planetary/apod")
+	}
+	if c.httpClient.Timeout != 5*time.Second {
+		t.Errorf("client has wrong timeout got %s, want %s", c.httpClient.Timeout, 5*time.Second)
+	}
+}
+
+func TestClientHTTPClientNotModified(t *testing.T) {
+	hc := &http.Client{Timeout: time.Minute}
+	c := NewClient(WithHTTPClient(hc), WithTimeout(time.Second))
+	if hc.Timeout != time.Minute {
+		t.Errorf("WithTimeout modified the http.Client provided")
+	}
+	if c.httpClient.Timeout != time.Second {
+		t.Errorf("client has wrong timeout got %s, want %s", c.httpClient.Timeout, time.Second)
+	}
+}
+
+func TestDefaultClientEndpoints(t *testing.T) {
+	old := NeoEndpoint
+	defer func() { NeoEndpoint = old }()
+	NeoEndpoint = "http://example.com/neo"
+	if got := DefaultClient.neoURL(); got != NeoEndpoint {
+		t.Errorf("DefaultClient ignores NeoEndpoint got %q, want %q", got, NeoEndpoint)
+	}
+}
+
+func TestClientContextCanceled(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		<-r.Context().Done()
+	}))
+	defer ts.Close()
+
+	c := NewClient(WithBaseURL(ts.URL))
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	_, err := c.NeoFeedContext(ctx, time.Now(), time.Now())
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("NeoFeedContext returned wrong error got %v, want %v", err, context.DeadlineExceeded)
+	}
+}
@@ -1,27 +1,50 @@
 package main
 
 import (
+	"context"
 	"errors"
 	"flag"
 	"fmt"
+	"image"
 	"io/ioutil"
 	"log"
+	"log/slog"
 	"net/http"
 	"os"
 	"os/exec"
+	"os/signal"
 	"strings"
+	"syscall"
 	"time"
 
 	"github.com/peteretelej/nasa"
+	"github.com/peteretelej/nasa/cassette"
 )
 
 // nasa-wallpapers Flags
 var (
 	random   = flag.Bool("random", true, "use random pictures, if false will only display today's APOD")
 	interval = flag.Duration("interval", time.Minute*10, "interval to change wallpaper")
+	seed     = flag.Int64("seed", 0, "pick random pictures from the whole archive in a sequence seeded by seed, displays with the same seed show the same pictures")
+
+	minWidth     = flag.Int("min-width", 1024, "skip pictures narrower than this, in pixels")
+	minHeight    = flag.Int("min-height", 600, "skip pictures shorter than this, in pixels")
+	minLuminance = flag.Float64("min-luminance", 0.05, "skip pictures darker than this mean luminance, from 0 (black) to 1 (white)")
+	orientation  = flag.String("orientation", "landscape", "skip pictures not of this orientation: landscape, portrait, square or any")
+
+	size = flag.String("size", "", "screen size to fit pictures to, e.g. 1920x1080, detected with xrandr if not set")
+	fit  = flag.String("fit", "none", "how pictures are fitted to the screen: crop, smart, letterbox, or none to set the original picture")
+
+	caption         = flag.String("caption", "", "draw the title, date and credit of pictures at bottom-left, bottom-right, top-left or top-right")
+	captionSize     = flag.Int("caption-size", 0, "height of caption lines in pixels, 0 for a 40th of the picture's height")
+	captionBackdrop = flag.Float64("caption-backdrop", nasa.DefaultCaptionBackdrop, "opacity of the backdrop behind captions, from 0 to 1")
 
 	cmdString  = flag.String("cmd", "", "command string to change the wallpaper")
 	cmdDefault = flag.String("cmdDefault", "", "use a default command to set the wallpaper")
+
+	cassetteFile = flag.String("cassette", "", "replay NASA API responses and images from a cassette file, e.g. for demos")
+	record       = flag.Bool("record", false, "record NASA API responses and images to -cassette instead of replaying")
+	verbose      = flag.Bool("verbose", false, "log NASA API requests")
 )
 
 // Commands for changing wallpapers
@@ -42,7 +65,7 @@ var (
 
 func init() {
 	if os.Getenv("NASAKEY") == "" {
-		fmt.Println(nasa.APIKEYMissing)
+		fmt.Print(nasa.APIKEYMissing)
 	}
 }
 
@@ -53,23 +76,87 @@ func main() {
 		log.Fatal("wallpapers change command not found, set custom one with -cmd")
 	}
 	cmds = strings.Split(fmt.Sprintf(realCmdString, tmpfile), " ")
+
+	var opts []nasa.Option
+	if *verbose {
+		opts = append(opts, nasa.WithHooks(nasa.SlogHooks(slog.Default())))
+	}
+	if *cassetteFile != "" {
+		mode := cassette.Replay
+		if *record {
+			mode = cassette.Record
+		}
+		rec, err := cassette.NewRecorder(*cassetteFile, mode)
+		if err != nil {
+			log.Fatalf("nasa-wallpapers: %v", err)
+		}
+		if *record {
+			defer func() {
+				if err := rec.Save(); err != nil {
+					log.Printf("nasa-wallpapers: unable to save cassette: %v", err)
+				}
+			}()
+		}
+		opts = append(opts, nasa.WithHTTPClient(&http.Client{Transport: rec}))
+	}
+	if len(opts) > 0 {
+		nasa.DefaultClient = nasa.NewClient(opts...)
+	}
+	switch *orientation {
+	case string(nasa.Landscape), string(nasa.Portrait), string(nasa.Square), "any":
+	default:
+		log.Fatalf("nasa-wallpapers: invalid -orientation %q", *orientation)
+	}
+	if *fit != "none" {
+		mode, err := nasa.ParseFitMode(*fit)
+		if err != nil {
+			log.Fatalf("nasa-wallpapers: -fit: %v", err)
+		}
+		w, h, err := screenSize()
+		if err != nil {
+			log.Printf("nasa-wallpapers: pictures won't be fitted to the screen: %v", err)
+		} else {
+			process = append(process, nasa.ProcessFit(w, h, mode))
+		}
+	}
+	if *caption != "" {
+		pos, err := nasa.ParseCaptionPosition(*caption)
+		if err != nil {
+			log.Fatalf("nasa-wallpapers: -caption: %v", err)
+		}
+		captionOpts = []nasa.CaptionOption{nasa.CaptionAt(pos), nasa.CaptionSize(*captionSize), nasa.CaptionBackdrop(*captionBackdrop)}
+	}
+	if *seed != 0 {
+		next = nasa.NewRandomSelector(nasa.RandomFullArchive(), nasa.RandomMediaTypes(nasa.MediaImage),
+			nasa.RandomNoRepeat(100), nasa.RandomSeed(*seed)).Next
+	} else {
+		pool := nasa.NewRandomPool(20)
+		// videos are shown using their thumbnails
+		pool.Filter = func(apod nasa.Image) bool { return apod.PictureURL(true) != "" }
+		next = pool.Next
+	}
+
+	// stop cleanly (and remove the tempfile) on interrupt
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
 	if !*random {
-		if err := todaysAPOD(); err != nil {
+		if err := todaysAPOD(ctx); err != nil {
 			log.Fatalf("nasa-wallpapers: %v\n", err)
 		}
 	}
 
-	if err := randomAPOD(*interval); err != nil {
+	if err := randomAPOD(ctx, *interval); err != nil {
 		log.Fatalf("nasa-wallpapers: %v\n", err)
 	}
 }
 
-func todaysAPOD() error {
+func todaysAPOD(ctx context.Context) error {
 	defer cleanUp()
 	return errors.New("TODO")
 }
 
-func randomAPOD(interval time.Duration) error {
+func randomAPOD(ctx context.Context, interval time.Duration) error {
 	defer cleanUp()
 	if interval < time.Second {
 		return errors.New("interval set is too low")
@@ -78,16 +165,22 @@ func randomAPOD(interval time.Duration) error {
 	fmt.Printf("nasa-wallpapers: resetting wallpaper to a random NASA APOD picture every %s\n", interval)
 	for {
 		var err error
-		for i := 0; i < 3; i++ {
-			err = updateRandom()
-			if err == nil {
+		// retry with a different pic when the random APOD is not an image (e.g. a video)
+		// or doesn't suit the screen
+		for i := 0; i < 5; i++ {
+			err = updateRandom(ctx)
+			if !errors.Is(err, errNotImage) && !errors.Is(err, errUnsuitable) {
 				break
 			}
 		}
-		if err != nil {
+		if err != nil && ctx.Err() == nil {
 			log.Printf("nasa-wallpapers: unable to fetch wallpapers: %v", err)
 		}
-		time.Sleep(interval)
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-time.After(interval):
+		}
 	}
 }
 
@@ -129,49 +222,140 @@ func getCmdString() string {
 	return ""
 }
 
-func updateRandom() error {
-	apod, err := nasa.RandomAPOD()
+// next returns the next random APOD, from a seeded selector or a pool buffering
+// random APODs, so each wallpaper change rarely needs an API request
+var next func(ctx context.Context) (*nasa.Image, error)
+
+// errNotImage is returned when the APOD picked is not an image
+var errNotImage = errors.New("APOD is not an image")
+
+// errUnsuitable is returned when the APOD picked is too small, dark or of the wrong orientation
+var errUnsuitable = errors.New("APOD picture is not suitable as a wallpaper")
+
+// unsuitable remembers the APODs whose pictures were skipped, so they aren't downloaded again
+var unsuitable = make(map[nasa.Date]bool)
+
+func updateRandom(ctx context.Context) error {
+	apod, err := next(ctx)
 	if err != nil {
 		return err
 	}
-	if apod.HDURL == "" {
-		return errors.New("invalid response from NASA API")
+	if unsuitable[apod.Date] {
+		return fmt.Errorf("%w: skipped %s before", errUnsuitable, apod.Date)
+	}
+	_, err = nasa.DownloadImageFileContext(ctx, *apod, srcfile())
+	if errors.Is(err, nasa.ErrNoPicture) || errors.Is(err, nasa.ErrUnexpectedMimeType) {
+		return fmt.Errorf("%w: %v", errNotImage, err)
 	}
-	req, err := http.NewRequest("GET", apod.HDURL, nil)
 	if err != nil {
 		return err
 	}
-	cl := &http.Client{Timeout: time.Second * 40}
-
-	resp, err := cl.Do(req)
-	if err != nil {
+	if err := checkSuitable(srcfile()); err != nil {
+		unsuitable[apod.Date] = true
+		return fmt.Errorf("%w: %s %v", errUnsuitable, apod.Date, err)
+	}
+	if err := processPicture(*apod); err != nil {
 		return err
 	}
-	if resp.StatusCode != http.StatusOK {
-		return fmt.Errorf("NASA API Response not OK: %d %s",
-			resp.StatusCode, http.StatusText(resp.StatusCode))
+
+	_, err = exec.CommandContext(ctx, cmds[0], cmds[1:]...).Output()
+	return err
+}
+
+// checkSuitable returns an error if the picture at path doesn't pass the
+// -min-width, -min-height, -min-luminance and -orientation flags
+func checkSuitable(path string) error {
+	a, err := nasa.AnalyzeImageFile(path)
+	if errors.Is(err, image.ErrFormat) {
+		return nil // e.g. WebP, which can't be analyzed but can be displayed
 	}
-	defer func() { _ = resp.Body.Close() }()
-	dat, err := ioutil.ReadAll(resp.Body)
 	if err != nil {
 		return err
 	}
-	_ = resp.Body.Close()
-	if len(dat) < 512 {
-		return errors.New("invalid response from APOD image url")
+	switch {
+	case !a.Fits(*minWidth, *minHeight):
+		return fmt.Errorf("is %dx%d, smaller than %dx%d", a.Width, a.Height, *minWidth, *minHeight)
+	case a.Luminance < *minLuminance:
+		return fmt.Errorf("is too dark, luminance %.2f", a.Luminance)
+	case *orientation != "any" && string(a.Orientation()) != *orientation:
+		return fmt.Errorf("is %s", a.Orientation())
 	}
-	switch http.DetectContentType(dat[:512]) {
-	case "image/jpg", "image/jpeg", "image/png", "image/gif":
-	default:
-		return errors.New("Apod returned is not a valid image mimetype")
+	return nil
+}
+
+// process are the steps pictures are processed with before being set as the wallpaper,
+// and captionOpts the options of their captions, if captions are drawn
+var (
+	process     []nasa.ProcessOption
+	captionOpts []nasa.CaptionOption
+)
+
+// processing reports whether pictures are processed before being set as the wallpaper
+func processing() bool { return len(process) > 0 || captionOpts != nil }
+
+// srcfile returns the file pictures are downloaded to, the tmpfile itself unless
+// they are processed into it
+func srcfile() string {
+	if !processing() {
+		return tmpfile
+	}
+	return tmpfile + ".src"
+}
+
+// processPicture processes the downloaded picture of apod into the tmpfile
+func processPicture(apod nasa.Image) error {
+	if !processing() {
+		return nil
+	}
+	steps := process
+	if captionOpts != nil {
+		steps = append(steps[:len(steps):len(steps)], nasa.ProcessCaption(apod, captionOpts...))
 	}
-	err = ioutil.WriteFile(tmpfile, dat, 0644)
+	err := nasa.ProcessImageFile(srcfile(), tmpfile, steps...)
+	if errors.Is(err, image.ErrFormat) {
+		// e.g. WebP, leave it to the wallpaper command to display
+		return os.Rename(srcfile(), tmpfile)
+	}
+	return err
+}
+
+// screenSize returns the -size flag, or the size of the primary screen (or the
+// first connected one) reported by xrandr
+func screenSize() (width, height int, err error) {
+	if *size != "" {
+		return parseSize(*size)
+	}
+	out, err := exec.Command("xrandr", "--current").Output()
 	if err != nil {
-		return err
+		return 0, 0, fmt.Errorf("unable to detect the screen size, set -size: %v", err)
+	}
+	var found string
+	for _, line := range strings.Split(string(out), "\n") {
+		fields := strings.Fields(line)
+		if len(fields) < 3 || fields[1] != "connected" {
+			continue
+		}
+		for _, f := range fields[2:] {
+			if i := strings.IndexByte(f, '+'); i > 0 && strings.Contains(f[:i], "x") {
+				if found == "" || fields[2] == "primary" {
+					found = f[:i]
+				}
+				break
+			}
+		}
+	}
+	if found == "" {
+		return 0, 0, errors.New("unable to detect the screen size from xrandr, set -size")
 	}
+	return parseSize(found)
+}
 
-	_, err = exec.Command(cmds[0], cmds[1:]...).Output()
-	return err
+// parseSize parses a size such as 1920x1080
+func parseSize(s string) (width, height int, err error) {
+	if _, err := fmt.Sscanf(s, "%dx%d", &width, &height); err != nil || width < 1 || height < 1 {
+		return 0, 0, fmt.Errorf("invalid size %q, should be WIDTHxHEIGHT e.g. 1920x1080", s)
+	}
+	return width, height, nil
 }
 
 var tmpfile string
@@ -191,6 +375,11 @@ func cleanUp() {
 	if tmpfile == "" {
 		return
 	}
+	_ = os.Remove(srcfile() + ".part") // left by an interrupted download
+	_ = os.Remove(srcfile() + ".part.json")
+	if processing() {
+		_ = os.Remove(srcfile())
+	}
 	if _, err := os.Stat(tmpfile); err != nil {
 		return
 	}
@@ -1,10 +1,19 @@
 package main
 
 import (
+	"context"
+	"errors"
 	"flag"
 	"fmt"
 	"log"
+	"log/slog"
+	"net"
+	"net/http"
 	"os"
+	"os/signal"
+	"path/filepath"
+	"strings"
+	"syscall"
 	"time"
 
 	"github.com/peteretelej/nasa"
@@ -14,6 +23,30 @@ import (
 var (
 	apodCommand = flag.NewFlagSet("apod", flag.ExitOnError)
 	apodDate    = apodCommand.String("date", "", "APOD on a particular date YYYY-MM-DD")
+	apodStart   = apodCommand.String("start", "", "APODs from a start date YYYY-MM-DD")
+	apodEnd     = apodCommand.String("end", "", "APODs up to an end date YYYY-MM-DD, defaults to today")
+	apodSave    = apodCommand.String("download", "", "download the APOD's HD picture to a file, or to a directory named by date")
+	apodBrief   = apodCommand.Bool("brief", false, "print the first sentence of explanations, with the credits, objects and links parsed from them")
+
+	syncCommand     = flag.NewFlagSet("apod sync", flag.ExitOnError)
+	syncDir         = syncCommand.String("dir", "apod", "directory to mirror APODs to")
+	syncStart       = syncCommand.String("start", "", "mirror APODs from a start date YYYY-MM-DD, defaults to the first APOD")
+	syncEnd         = syncCommand.String("end", "", "mirror APODs up to an end date YYYY-MM-DD, defaults to today")
+	syncConcurrency = syncCommand.Int("concurrency", nasa.DefaultSyncConcurrency, "number of pictures to download at once")
+	syncSD          = syncCommand.Bool("sd", false, "download SD pictures instead of HD")
+	syncNoPictures  = syncCommand.Bool("metadata-only", false, "only mirror the metadata index, no pictures")
+	syncReserve     = syncCommand.Int("quota-reserve", 0, "stop once the API key has this many requests left this hour, for other uses of the key")
+
+	searchCommand = flag.NewFlagSet("apod search", flag.ExitOnError)
+	searchDir     = searchCommand.String("dir", "apod", "directory of the APOD mirror to search, see apod sync")
+	searchStart   = searchCommand.String("start", "", "fetch the APODs from a start date YYYY-MM-DD to the mirror before searching")
+	searchEnd     = searchCommand.String("end", "", "fetch the APODs up to an end date YYYY-MM-DD, defaults to today")
+	searchLimit   = searchCommand.Int("limit", 10, "maximum number of results")
+
+	onThisDayCommand = flag.NewFlagSet("apod onthisday", flag.ExitOnError)
+	onThisDayDate    = onThisDayCommand.String("date", "", "month and day MM-DD, defaults to the latest APOD's")
+	onThisDayWorkers = onThisDayCommand.Int("workers", nasa.DefaultIterWorkers, "number of APODs to fetch at once")
+	onThisDayCache   = onThisDayCommand.String("cache", "", "directory to cache NASA API responses in, for faster reruns")
 
 	neoCommand = flag.NewFlagSet("neo", flag.ExitOnError)
 	neoStart   = neoCommand.String("start", "", "NEO start date YYYY-MM-DD")
@@ -21,11 +54,17 @@ var (
 
 	webCommand = flag.NewFlagSet("web", flag.ExitOnError)
 	webListen  = webCommand.String("listen", ":8080", "http web server address")
+	webCache   = webCommand.String("cache", "", "directory to cache NASA API responses in")
+	webVerbose = webCommand.Bool("verbose", false, "log NASA API requests")
+	webMirror  = webCommand.String("mirror", "", "directory of an APOD mirror to search, see apod sync, defaults to the last year's APODs")
+
+	quotaCommand = flag.NewFlagSet("quota", flag.ExitOnError)
 )
 
 func init() {
+	onThisDayCommand.BoolVar(apodBrief, "brief", false, apodCommand.Lookup("brief").Usage)
 	if os.Getenv("NASAKEY") == "" {
-		fmt.Println(nasa.APIKEYMissing)
+		fmt.Print(nasa.APIKEYMissing)
 	}
 }
 
@@ -34,34 +73,57 @@ func main() {
 	if len(os.Args) == 1 {
 		os.Args = append(os.Args, "apod")
 	}
+	// cancel in-flight NASA API requests on interrupt
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
 
 	switch os.Args[1] {
 	case "apod":
-		t := time.Now()
+		if len(os.Args) > 2 && os.Args[2] == "sync" {
+			_ = syncCommand.Parse(os.Args[3:]) // exits on error
+			apodSync(ctx)
+			return
+		}
+		if len(os.Args) > 2 && os.Args[2] == "search" {
+			_ = searchCommand.Parse(os.Args[3:]) // exits on error
+			apodSearch(ctx, strings.Join(searchCommand.Args(), " "))
+			return
+		}
+		if len(os.Args) > 2 && os.Args[2] == "onthisday" {
+			_ = onThisDayCommand.Parse(os.Args[3:]) // exits on error
+			apodOnThisDay(ctx)
+			return
+		}
 		if len(os.Args) > 2 {
 			_ = apodCommand.Parse(os.Args[2:]) // exits on error
 		}
+		if *apodStart != "" {
+			apodRange(ctx, *apodStart, *apodEnd)
+			return
+		}
+		d := nasa.LatestAPODDate()
 		if *apodDate != "" {
 			var err error
-			t, err = time.Parse("2006-01-02", *apodDate)
-			if err != nil {
-				fmt.Printf("nasa apod: invalid -date, should use format YYYY-MM-DD\n")
-				os.Exit(1)
+			if d, err = nasa.ParseDate(*apodDate); err != nil {
+				fmt.Printf("nasa apod: -date: %v\n", err)
 				os.Exit(1)
 			}
 		}
-		apod, err := nasa.ApodImage(t)
+		apod, err := nasa.APODDateContext(ctx, d)
 		if err != nil {
 			fmt.Printf("unable to get apod: %v\n", err)
 			os.Exit(1)
 		}
-		fmt.Println(apod)
+		printAPOD(*apod)
+		if *apodSave != "" {
+			downloadAPOD(ctx, *apod, *apodSave)
+		}
 	case "neo":
 		if len(os.Args) > 2 {
 			_ = neoCommand.Parse(os.Args[2:]) //exits on error
 		}
 		start, end := *neoStart, *neoEnd
-		today := time.Now().Format("2006-01-02")
+		today := nasa.LatestAPODDate().String()
 		if start == "" {
 			start = today
 		}
@@ -78,24 +140,273 @@ func main() {
 			fmt.Printf("nasa neo: invalid -end date, should be YYYY-MM-DD\n")
 			os.Exit(1)
 		}
-		nl, err := nasa.NeoFeed(st, et)
+		nl, err := nasa.NeoFeedContext(ctx, st, et)
 		if err != nil {
 			fmt.Printf("nasa neo: %v", err)
 			os.Exit(1)
 		}
 		fmt.Println(nl)
+	case "quota":
+		if len(os.Args) > 2 {
+			_ = quotaCommand.Parse(os.Args[2:]) //exits on error
+		}
+		// the quota is only reported in API responses, make a cheap request
+		_, err := nasa.APODTodayContext(ctx)
+		q := nasa.GetQuota()
+		if !q.Known() {
+			fmt.Printf("nasa quota: unable to get quota: %v\n", err)
+			os.Exit(1)
+		}
+		fmt.Print(q)
+		if keys := nasa.GetKeyHealth(); len(keys) > 1 {
+			for _, k := range keys {
+				status := "ok"
+				switch {
+				case k.Invalid:
+					status = "invalid"
+				case k.Exhausted:
+					status = "exhausted until " + k.Quota.Resets().Format(time.Kitchen)
+				case !k.Quota.Known():
+					status = "not used yet"
+				}
+				fmt.Printf("Key %s: %d/%d remaining, %s\n", k.Key, k.Quota.Remaining, k.Quota.Limit, status)
+			}
+		}
 	case "web":
 		if len(os.Args) > 2 {
 			_ = webCommand.Parse(os.Args[2:]) //exits on error
 		}
+		var opts []nasa.Option
+		if *webCache != "" {
+			cache, err := nasa.NewDiskCache(*webCache)
+			if err != nil {
+				fmt.Printf("nasa web: unable to use -cache: %v\n", err)
+				os.Exit(1)
+			}
+			opts = append(opts, nasa.WithCache(cache))
+		}
+		if *webVerbose {
+			opts = append(opts, nasa.WithHooks(nasa.SlogHooks(slog.Default())))
+		}
+		if len(opts) > 0 {
+			nasa.DefaultClient = nasa.NewClient(opts...)
+		}
+		if *webMirror != "" {
+			m, err := nasa.OpenMirror(*webMirror)
+			if err != nil {
+				fmt.Printf("nasa web: unable to use -mirror: %v\n", err)
+				os.Exit(1)
+			}
+			ix := m.SearchIndex()
+			nasa.SearchIndexFunc = func(context.Context) (*nasa.SearchIndex, error) { return ix, nil }
+		}
 		svr, err := nasa.NewServer(*webListen)
 		if err != nil {
 			fmt.Printf("failed to launch webserver: %v\n", err)
 			os.Exit(1)
 		}
+		svr.BaseContext = func(net.Listener) context.Context { return ctx }
+		go func() {
+			<-ctx.Done()
+			shutdownCtx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+			defer cancel()
+			_ = svr.Shutdown(shutdownCtx)
+		}()
 		fmt.Printf("nasa web: launching http server at %s\n", svr.Addr)
-		if err := svr.ListenAndServe(); err != nil {
+		if err := svr.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
 			log.Fatalf("server crashed: %v", err)
 		}
 	}
 }
+
+// downloadAPOD downloads the picture of apod to path, a file or directory
+func downloadAPOD(ctx context.Context, apod nasa.Image, path string) {
+	if fi, err := os.Stat(path); err == nil && fi.IsDir() {
+		ext := filepath.Ext(apod.PictureURL(true))
+		if ext == "" || len(ext) > 5 {
+			ext = ".jpg"
+		}
+		path = filepath.Join(path, apod.Date.String()+ext)
+	}
+	res, err := nasa.DownloadImageFileContext(ctx, apod, path)
+	if err != nil {
+		fmt.Printf("nasa apod: unable to download picture: %v\n", err)
+		os.Exit(1)
+	}
+	fmt.Printf("Downloaded %s to %s (%s, %d bytes, sha256 %s)\n", res.URL, path, res.ContentType, res.Size, res.SHA256)
+}
+
+// apodSync mirrors APODs and their pictures to -dir
+func apodSync(ctx context.Context) {
+	var from, to nasa.Date
+	var err error
+	if *syncStart != "" {
+		if from, err = nasa.ParseDate(*syncStart); err != nil {
+			fmt.Printf("nasa apod sync: -start: %v\n", err)
+			os.Exit(1)
+		}
+	}
+	if *syncEnd != "" {
+		if to, err = nasa.ParseDate(*syncEnd); err != nil {
+			fmt.Printf("nasa apod sync: -end: %v\n", err)
+			os.Exit(1)
+		}
+	}
+	m, err := nasa.OpenMirror(*syncDir)
+	if err != nil {
+		fmt.Printf("nasa apod sync: %v\n", err)
+		os.Exit(1)
+	}
+	opts := []nasa.SyncOption{
+		nasa.SyncConcurrency(*syncConcurrency),
+		nasa.SyncQuotaReserve(*syncReserve),
+		nasa.SyncProgressFunc(func(p nasa.SyncProgress) {
+			switch {
+			case p.Err != nil && ctx.Err() == nil:
+				fmt.Printf("%s: %v\n", p.From, p.Err)
+			case p.Picture:
+				fmt.Printf("\rpictures: %d/%d", p.Done, p.Total)
+				if p.Done == p.Total {
+					fmt.Println()
+				}
+			case p.Err == nil:
+				fmt.Printf("metadata: %s to %s\n", p.From, p.To)
+			}
+		}),
+	}
+	if *syncSD {
+		opts = append(opts, nasa.SyncDownloadOptions(nasa.DownloadSD()))
+	}
+	if *syncNoPictures {
+		opts = append(opts, nasa.SyncMetadataOnly())
+	}
+	r, err := m.Sync(ctx, from, to, opts...)
+	fmt.Println(r)
+	if err != nil {
+		fmt.Printf("nasa apod sync: %v\nrun it again to continue where it stopped\n", err)
+		os.Exit(1)
+	}
+	if len(r.Failures) > 0 {
+		os.Exit(1)
+	}
+}
+
+// apodSearch searches the APODs in the -dir mirror, fetching the -start to -end metadata
+// first. An empty mirror gets the last year's APODs.
+func apodSearch(ctx context.Context, query string) {
+	q := nasa.ParseQuery(query)
+	if q.IsEmpty() {
+		fmt.Println("usage: nasa apod search [flags] <query>, e.g. horsehead \"dark nebula\" from:2010-01-01")
+		os.Exit(1)
+	}
+	m, err := nasa.OpenMirror(*searchDir)
+	if err != nil {
+		fmt.Printf("nasa apod search: %v\n", err)
+		os.Exit(1)
+	}
+	if *searchStart != "" || m.Len() == 0 {
+		from := nasa.LatestAPODDate().AddDays(-364)
+		var to nasa.Date
+		if *searchStart != "" {
+			if from, err = nasa.ParseDate(*searchStart); err != nil {
+				fmt.Printf("nasa apod search: -start: %v\n", err)
+				os.Exit(1)
+			}
+		}
+		if *searchEnd != "" {
+			if to, err = nasa.ParseDate(*searchEnd); err != nil {
+				fmt.Printf("nasa apod search: -end: %v\n", err)
+				os.Exit(1)
+			}
+		}
+		if _, err := m.Sync(ctx, from, to, nasa.SyncMetadataOnly()); err != nil {
+			fmt.Printf("nasa apod search: unable to fetch APODs: %v\n", err)
+			os.Exit(1)
+		}
+	}
+	results := m.SearchIndex().Search(q, *searchLimit)
+	if len(results) == 0 {
+		fmt.Printf("No APODs found in %d APODs from %s\n", m.Len(), *searchDir)
+		return
+	}
+	for _, r := range results {
+		fmt.Printf("%s  %s\n  %s\n  %s\n\n", r.Date, r.Title, r.PageURL(), r.Snippet)
+	}
+}
+
+// apodRange prints the APODs from start to end (default today)
+func apodRange(ctx context.Context, start, end string) {
+	st, err := nasa.ParseDate(start)
+	if err != nil {
+		fmt.Printf("nasa apod: -start: %v\n", err)
+		os.Exit(1)
+	}
+	et := nasa.LatestAPODDate()
+	if end != "" {
+		if et, err = nasa.ParseDate(end); err != nil {
+			fmt.Printf("nasa apod: -end: %v\n", err)
+			os.Exit(1)
+		}
+	}
+	apods, err := nasa.APODRangeContext(ctx, st.Time(time.UTC), et.Time(time.UTC))
+	if err != nil {
+		fmt.Printf("unable to get apods: %v\n", err)
+		os.Exit(1)
+	}
+	for _, apod := range apods {
+		printAPOD(apod)
+	}
+}
+
+// apodOnThisDay prints the APODs of a month and day in every year, newest first
+func apodOnThisDay(ctx context.Context) {
+	latest := nasa.LatestAPODDate()
+	month, day := latest.Month, latest.Day
+	if *onThisDayDate != "" {
+		t, err := time.Parse("01-02", *onThisDayDate)
+		if err != nil {
+			fmt.Printf("nasa apod onthisday: -date: invalid date %q, should be MM-DD\n", *onThisDayDate)
+			os.Exit(1)
+		}
+		month, day = t.Month(), t.Day()
+	}
+	if *onThisDayCache != "" {
+		cache, err := nasa.NewDiskCache(*onThisDayCache)
+		if err != nil {
+			fmt.Printf("nasa apod onthisday: unable to use -cache: %v\n", err)
+			os.Exit(1)
+		}
+		nasa.DefaultClient = nasa.NewClient(nasa.WithCache(cache))
+	}
+	apods, err := nasa.OnThisDayContext(ctx, month, day, nasa.IterWorkers(*onThisDayWorkers))
+	for _, apod := range apods {
+		printAPOD(apod)
+	}
+	if err != nil {
+		fmt.Printf("nasa apod onthisday: %v\n", err)
+		os.Exit(1)
+	}
+}
+
+// printAPOD prints an APOD, briefly with -brief
+func printAPOD(apod nasa.Image) {
+	if !*apodBrief {
+		fmt.Println(apod)
+		return
+	}
+	e, err := nasa.ExplainAPOD(apod)
+	if err != nil {
+		fmt.Printf("%s: %v\n", apod.Date, err)
+	}
+	fmt.Printf("%s  %s\n  %s\n", apod.Date, apod.Title, e.Summary)
+	for _, c := range e.Credits {
+		fmt.Printf("  %s\n", c)
+	}
+	if len(e.Objects) > 0 {
+		fmt.Printf("  Objects: %s\n", strings.Join(e.Objects, ", "))
+	}
+	for _, l := range e.Links {
+		fmt.Printf("  %s <%s>\n", l.Text, l.URL)
+	}
+	fmt.Println()
+}
@@ -0,0 +1,138 @@
+package nasa
+
+import (
+	"encoding/json"
+	"fmt"
+	"time"
+)
+
+// Date is a civil date, without a time or time zone, e.g. the date of an APOD.
+// It marshals to and from JSON and text as YYYY-MM-DD. The zero Date is invalid.
+type Date struct {
+	Year  int
+	Month time.Month
+	Day   int
+}
+
+// DateLayout is the layout of dates used by the NASA API
+const DateLayout = "2006-01-02"
+
+// FirstAPODDate is the date of the first Astronomy Picture of the Day
+var FirstAPODDate = Date{1995, time.June, 16}
+
+// apodLocation is where APODs are published, a new APOD is out after midnight there
+var apodLocation = loadLocation("America/New_York", -5*60*60)
+
+func loadLocation(name string, offset int) *time.Location {
+	if loc, err := time.LoadLocation(name); err == nil {
+		return loc
+	}
+	return time.FixedZone(name, offset) // no tz database, ignore daylight saving
+}
+
+// NewDate returns the Date for year, month and day, normalizing them like time.Date
+// e.g. October 32 becomes November 1
+func NewDate(year int, month time.Month, day int) Date {
+	return DateOf(time.Date(year, month, day, 0, 0, 0, 0, time.UTC))
+}
+
+// DateOf returns the date of t in t's location
+func DateOf(t time.Time) Date {
+	y, m, d := t.Date()
+	return Date{y, m, d}
+}
+
+// ParseDate parses a YYYY-MM-DD date
+func ParseDate(s string) (Date, error) {
+	t, err := time.Parse(DateLayout, s)
+	if err != nil {
+		return Date{}, fmt.Errorf("invalid date %q, should be YYYY-MM-DD", s)
+	}
+	return DateOf(t), nil
+}
+
+// LatestAPODDate returns the date of the latest published APOD,
+// which is today's date in US Eastern time.
+func LatestAPODDate() Date {
+	return DateOf(time.Now().In(apodLocation))
+}
+
+// ValidateAPODDate returns an error matching ErrDateOutOfRange if there's no APOD for d
+// because it's before the first APOD or after the latest published one
+func ValidateAPODDate(d Date) error {
+	if d.Before(FirstAPODDate) {
+		return fmt.Errorf("%w: %s is before the first APOD on %s", ErrDateOutOfRange, d, FirstAPODDate)
+	}
+	if latest := LatestAPODDate(); d.After(latest) {
+		return fmt.Errorf("%w: %s is after the latest APOD on %s", ErrDateOutOfRange, d, latest)
+	}
+	return nil
+}
+
+// String returns the date as YYYY-MM-DD, empty for the zero Date
+func (d Date) String() string {
+	if d.IsZero() {
+		return ""
+	}
+	return fmt.Sprintf("%04d-%02d-%02d", d.Year, d.Month, d.Day)
+}
+
+// Time returns midnight of the date in loc
+func (d Date) Time(loc *time.Location) time.Time {
+	return time.Date(d.Year, d.Month, d.Day, 0, 0, 0, 0, loc)
+}
+
+// IsZero reports whether d is the zero Date
+func (d Date) IsZero() bool { return d == Date{} }
+
+// AddDays returns the date n days after d, n may be negative
+func (d Date) AddDays(n int) Date {
+	return DateOf(d.Time(time.UTC).AddDate(0, 0, n))
+}
+
+// Sub returns the number of days from u to d
+func (d Date) Sub(u Date) int {
+	return int(d.Time(time.UTC).Sub(u.Time(time.UTC)).Hours() / 24)
+}
+
+// Before reports whether d is before u
+func (d Date) Before(u Date) bool { return d.Sub(u) < 0 }
+
+// After reports whether d is after u
+func (d Date) After(u Date) bool { return d.Sub(u) > 0 }
+
+// MarshalText implements encoding.TextMarshaler
+func (d Date) MarshalText() ([]byte, error) {
+	return []byte(d.String()), nil
+}
+
+// UnmarshalText implements encoding.TextUnmarshaler, an empty text is the zero Date
+func (d *Date) UnmarshalText(text []byte) error {
+	if len(text) == 0 {
+		*d = Date{}
+		return nil
+	}
+	pd, err := ParseDate(string(text))
+	if err != nil {
+		return err
+	}
+	*d = pd
+	return nil
+}
+
+// MarshalJSON implements json.Marshaler
+func (d Date) MarshalJSON() ([]byte, error) {
+	return json.Marshal(d.String())
+}
+
+// UnmarshalJSON implements json.Unmarshaler, null and "" are the zero Date
+func (d *Date) UnmarshalJSON(dat []byte) error {
+	if string(dat) == "null" {
+		return nil
+	}
+	var s string
+	if err := json.Unmarshal(dat, &s); err != nil {
+		return fmt.Errorf("invalid date %s: %v", dat, err)
+	}
+	return d.UnmarshalText([]byte(s))
+}
@@ -0,0 +1,119 @@
+package nasa
+
+import (
+	"encoding/json"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestParseDate(t *testing.T) {
+	tests := []struct {
+		s    string
+		want Date
+		err  bool
+	}{
+		{"2017-05-11", Date{2017, time.May, 11}, false},
+		{"1995-06-16", FirstAPODDate, false},
+		{"2017-5-11", Date{}, true},
+		{"2017-02-30", Date{}, true},
+		{"", Date{}, true},
+	}
+	for _, v := range tests {
+		got, err := ParseDate(v.s)
+		if (err != nil) != v.err {
+			t.Errorf("ParseDate(%q) returned wrong error got %v, want error %t", v.s, err, v.err)
+		}
+		if got != v.want {
+			t.Errorf("ParseDate(%q) returned wrong date got %v, want %v", v.s, got, v.want)
+		}
+	}
+}
+
+func TestDateArithmetic(t *testing.T) {
+	d := NewDate(2016, time.February, 28)
+	tests := []struct {
+		n    int
+		want Date
+	}{
+		{0, d},
+		{1, Date{2016, time.February, 29}},
+		{2, Date{2016, time.March, 1}},
+		{-59, Date{2015, time.December, 31}},
+		{366, Date{2017, time.February, 28}},
+	}
+	for _, v := range tests {
+		got := d.AddDays(v.n)
+		if got != v.want {
+			t.Errorf("AddDays(%d) returned wrong date got %s, want %s", v.n, got, v.want)
+		}
+		if sub := got.Sub(d); sub != v.n {
+			t.Errorf("Sub returned wrong number of days got %d, want %d", sub, v.n)
+		}
+	}
+	if !d.Before(d.AddDays(1)) || d.After(d.AddDays(1)) || d.Before(d) {
+		t.Errorf("Before/After returned wrong order for %s", d)
+	}
+	if got := NewDate(2017, time.October, 32); got != (Date{2017, time.November, 1}) {
+		t.Errorf("NewDate did not normalize got %s, want %s", got, "2017-11-01")
+	}
+	// dates are civil, a late evening in a western zone is still that day
+	pst := time.FixedZone("PST", -8*60*60)
+	if got := DateOf(time.Date(2017, 5, 11, 23, 30, 0, 0, pst)); got != (Date{2017, time.May, 11}) {
+		t.Errorf("DateOf returned wrong date got %s, want %s", got, "2017-05-11")
+	}
+}
+
+func TestDateJSON(t *testing.T) {
+	var v struct {
+		Date  Date         `json:"date"`
+		Empty Date         `json:"empty"`
+		Keys  map[Date]int `json:"keys"`
+	}
+	in := `{"date":"2017-05-11","empty":"","keys":{"2017-05-12":1}}`
+	if err := json.Unmarshal([]byte(in), &v); err != nil {
+		t.Fatal(err)
+	}
+	if v.Date != (Date{2017, time.May, 11}) || !v.Empty.IsZero() || v.Keys[Date{2017, time.May, 12}] != 1 {
+		t.Errorf("json.Unmarshal returned wrong dates got %+v", v)
+	}
+	out, err := json.Marshal(v)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(out) != in {
+		t.Errorf("json.Marshal returned wrong json got %s, want %s", out, in)
+	}
+	if err := json.Unmarshal([]byte(`{"date":"11/05/2017"}`), &v); err == nil {
+		t.Errorf("json.Unmarshal returned no error for an invalid date")
+	}
+}
+
+func TestValidateAPODDate(t *testing.T) {
+	latest := LatestAPODDate()
+	tests := []struct {
+		d   Date
+		err error
+	}{
+		{FirstAPODDate, nil},
+		{FirstAPODDate.AddDays(-1), ErrDateOutOfRange},
+		{latest, nil},
+		{latest.AddDays(1), ErrDateOutOfRange},
+	}
+	for _, v := range tests {
+		if err := ValidateAPODDate(v.d); !errors.Is(err, v.err) {
+			t.Errorf("ValidateAPODDate(%s) returned wrong error got %v, want %v", v.d, err, v.err)
+		}
+	}
+	if _, err := APODDate(FirstAPODDate.AddDays(-1)); !errors.Is(err, ErrDateOutOfRange) {
+		t.Errorf("APODDate returned wrong error got %v, want %v", err, ErrDateOutOfRange)
+	}
+	// the fake API publishes in New York too, whatever the time of day in UTC
+	apod, err := NewClient(WithBaseURL(fakeAPI.URL)).APODDate(latest)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if apod.Date != latest {
+		t.Errorf("APODDate(%s) returned the APOD of wrong date got %s", latest, apod.Date)
+	}
+}
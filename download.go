@@ -0,0 +1,451 @@
+package nasa
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"hash"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Download errors
+var (
+	ErrNoPicture          = errors.New("nasa: APOD has no picture to download")
+	ErrUnexpectedMimeType = errors.New("nasa: downloaded file is not of an allowed content type")
+	ErrDownloadTooLarge   = errors.New("nasa: download exceeds the size limit")
+)
+
+// DefaultMaxDownloadSize is the largest picture downloaded unless DownloadMaxSize is set
+const DefaultMaxDownloadSize = 100 << 20
+
+// DefaultDownloadTimeout is how long a download may take unless DownloadTimeout is set
+const DefaultDownloadTimeout = 5 * time.Minute
+
+// ImageContentTypes are the content types downloaded by default
+var ImageContentTypes = []string{"image/jpeg", "image/png", "image/gif", "image/webp"}
+
+// sniffLen is the number of bytes needed to detect the content type of a file
+const sniffLen = 512
+
+// DownloadResult describes a completed download
+type DownloadResult struct {
+	URL         string `json:"url"`
+	ContentType string `json:"content_type"` // detected from the content
+	Size        int64  `json:"size"`         // bytes, including any resumed part
+	SHA256      string `json:"sha256"`       // hex checksum of the whole file
+	Resumed     bool   `json:"resumed"`      // whether a partial download was resumed
+}
+
+// DownloadOption configures a download
+type DownloadOption func(*downloadOptions)
+
+type downloadOptions struct {
+	sd      bool
+	maxSize int64
+	types   []string
+	timeout time.Duration
+}
+
+// DownloadSD downloads the standard definition picture instead of the HD one
+func DownloadSD() DownloadOption {
+	return func(o *downloadOptions) { o.sd = true }
+}
+
+// DownloadMaxSize fails downloads larger than n bytes with ErrDownloadTooLarge
+func DownloadMaxSize(n int64) DownloadOption {
+	return func(o *downloadOptions) { o.maxSize = n }
+}
+
+// DownloadContentTypes sets the content types allowed, detected from the content
+// as http.DetectContentType does. Others fail with ErrUnexpectedMimeType.
+func DownloadContentTypes(types ...string) DownloadOption {
+	return func(o *downloadOptions) { o.types = types }
+}
+
+// DownloadTimeout sets how long a download may take, including retries
+func DownloadTimeout(d time.Duration) DownloadOption {
+	return func(o *downloadOptions) { o.timeout = d }
+}
+
+func newDownloadOptions(opts []DownloadOption) downloadOptions {
+	o := downloadOptions{
+		maxSize: DefaultMaxDownloadSize,
+		types:   ImageContentTypes,
+		timeout: DefaultDownloadTimeout,
+	}
+	for _, opt := range opts {
+		opt(&o)
+	}
+	return o
+}
+
+// DownloadImage streams the HD picture of the APOD (the thumbnail for videos) to w,
+// using DefaultClient. See Client.DownloadImageContext.
+func DownloadImage(apod Image, w io.Writer, opts ...DownloadOption) (*DownloadResult, error) {
+	return DefaultClient.DownloadImageContext(context.Background(), apod, w, opts...)
+}
+
+// DownloadImageContext is like DownloadImage but uses ctx for the requests
+func DownloadImageContext(ctx context.Context, apod Image, w io.Writer, opts ...DownloadOption) (*DownloadResult, error) {
+	return DefaultClient.DownloadImageContext(ctx, apod, w, opts...)
+}
+
+// DownloadImageFile downloads the HD picture of the APOD (the thumbnail for videos)
+// to path, using DefaultClient. See Client.DownloadImageFileContext.
+func DownloadImageFile(apod Image, path string, opts ...DownloadOption) (*DownloadResult, error) {
+	return DefaultClient.DownloadImageFileContext(context.Background(), apod, path, opts...)
+}
+
+// DownloadImageFileContext is like DownloadImageFile but uses ctx for the requests
+func DownloadImageFileContext(ctx context.Context, apod Image, path string, opts ...DownloadOption) (*DownloadResult, error) {
+	return DefaultClient.DownloadImageFileContext(ctx, apod, path, opts...)
+}
+
+// DownloadImage streams the HD picture of the APOD (the thumbnail for videos) to w
+func (c *Client) DownloadImage(apod Image, w io.Writer, opts ...DownloadOption) (*DownloadResult, error) {
+	return c.DownloadImageContext(context.Background(), apod, w, opts...)
+}
+
+// DownloadImageContext is like DownloadImage but uses ctx for the requests.
+// Nothing is written to w until the content type has been validated. Interrupted
+// downloads are retried with the client's RetryPolicy, resuming with Range requests.
+func (c *Client) DownloadImageContext(ctx context.Context, apod Image, w io.Writer, opts ...DownloadOption) (*DownloadResult, error) {
+	o := newDownloadOptions(opts)
+	rawurl := apod.PictureURL(!o.sd)
+	if rawurl == "" {
+		return nil, ErrNoPicture
+	}
+	d := newDownloader(w, o)
+	if err := c.download(ctx, rawurl, d); err != nil {
+		return nil, err
+	}
+	return d.result(rawurl), nil
+}
+
+// DownloadImageFile downloads the HD picture of the APOD (the thumbnail for videos) to path
+func (c *Client) DownloadImageFile(apod Image, path string, opts ...DownloadOption) (*DownloadResult, error) {
+	return c.DownloadImageFileContext(context.Background(), apod, path, opts...)
+}
+
+// DownloadImageFileContext is like DownloadImageFile but uses ctx for the requests.
+// The picture is downloaded to path.part and renamed to path once complete, so path
+// is never left half written. A path.part left by an earlier attempt is resumed if
+// it's of the same URL and the server still has the same version of the picture,
+// as recorded in path.part.json.
+func (c *Client) DownloadImageFileContext(ctx context.Context, apod Image, path string, opts ...DownloadOption) (*DownloadResult, error) {
+	o := newDownloadOptions(opts)
+	rawurl := apod.PictureURL(!o.sd)
+	if rawurl == "" {
+		return nil, ErrNoPicture
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return nil, err
+	}
+	part, infoPath := path+".part", path+".part.json"
+	f, err := os.OpenFile(part, os.O_RDWR|os.O_CREATE, 0644)
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = f.Close() }()
+
+	d := newDownloader(f, o)
+	d.reset = func() error { return truncate(f) }
+	if err := d.resume(f, rawurl, readPartInfo(infoPath)); err != nil {
+		return nil, err
+	}
+	_ = os.Remove(infoPath) // rewritten if the download is interrupted again
+	if err := c.download(ctx, rawurl, d); err != nil {
+		// keep what was received to resume from, unless it's of no use
+		if d.n == 0 || errors.Is(err, ErrUnexpectedMimeType) || errors.Is(err, ErrDownloadTooLarge) ||
+			writePartInfo(infoPath, d.partInfo(rawurl)) != nil {
+			_ = f.Close()
+			_ = os.Remove(part)
+		}
+		return nil, err
+	}
+	if err := f.Sync(); err != nil {
+		return nil, err
+	}
+	if err := f.Close(); err != nil {
+		return nil, err
+	}
+	if err := os.Rename(part, path); err != nil {
+		return nil, err
+	}
+	return d.result(rawurl), nil
+}
+
+// partInfo records what a partial download is of, to only resume it from the same picture
+type partInfo struct {
+	URL          string `json:"url"`
+	ETag         string `json:"etag,omitempty"`
+	LastModified string `json:"last_modified,omitempty"`
+}
+
+// readPartInfo reads the partInfo at name, the zero partInfo if there's none
+func readPartInfo(name string) partInfo {
+	var info partInfo
+	if b, err := os.ReadFile(name); err == nil {
+		_ = json.Unmarshal(b, &info)
+	}
+	return info
+}
+
+func writePartInfo(name string, info partInfo) error {
+	b, err := json.Marshal(info)
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(name, b, 0644)
+}
+
+// download fetches rawurl into d, retrying and resuming interrupted downloads
+func (c *Client) download(ctx context.Context, rawurl string, d *downloader) error {
+	ctx, cancel := context.WithTimeout(ctx, d.o.timeout)
+	defer cancel()
+	// the API client's timeout is for small responses, ctx bounds the download
+	cl := *c.HTTPClient()
+	cl.Timeout = 0
+	err := c.retry.Do(ctx, func(attempt int) error {
+		return d.fetch(ctx, &cl, c.userAgent, rawurl)
+	})
+	if err != nil {
+		return fmt.Errorf("unable to download %s: %w", rawurl, err)
+	}
+	return d.finish()
+}
+
+// downloader validates, hashes and writes a download, keeping track of how much
+// has been received to resume from
+type downloader struct {
+	w       io.Writer
+	o       downloadOptions
+	hash    hash.Hash
+	n       int64  // bytes received
+	head    []byte // first bytes, held back until the content type is validated
+	ctype   string // set once validated
+	resumed bool
+
+	// validators of the picture, sent with If-Range when resuming
+	etag         string
+	lastModified string
+
+	reset func() error // starts the output over, nil if it can't be
+}
+
+func newDownloader(w io.Writer, o downloadOptions) *downloader {
+	return &downloader{w: w, o: o, hash: sha256.New()}
+}
+
+// resume continues from the partial download in f, described by info. Parts of
+// another URL or too short to validate are started over, as are parts of the
+// wrong content type.
+func (d *downloader) resume(f *os.File, rawurl string, info partInfo) error {
+	fi, err := f.Stat()
+	if err != nil {
+		return err
+	}
+	if info.URL == rawurl && fi.Size() >= sniffLen && fi.Size() <= d.o.maxSize {
+		head := make([]byte, sniffLen)
+		if _, err := io.ReadFull(f, head); err != nil {
+			return err
+		}
+		if ctype, err := d.validate(head); err == nil {
+			if _, err := f.Seek(0, io.SeekStart); err != nil {
+				return err
+			}
+			n, err := io.Copy(d.hash, f)
+			if err != nil {
+				return err
+			}
+			d.n, d.ctype, d.resumed = n, ctype, true
+			d.etag, d.lastModified = info.ETag, info.LastModified
+			return nil
+		}
+	}
+	return truncate(f)
+}
+
+// truncate empties f and seeks to its start
+func truncate(f *os.File) error {
+	if err := f.Truncate(0); err != nil {
+		return err
+	}
+	_, err := f.Seek(0, io.SeekStart)
+	return err
+}
+
+func (d *downloader) partInfo(rawurl string) partInfo {
+	return partInfo{URL: rawurl, ETag: d.etag, LastModified: d.lastModified}
+}
+
+// ifRange returns the validator to send with If-Range, weak ETags can't be used
+func (d *downloader) ifRange() string {
+	if d.etag != "" && !strings.HasPrefix(d.etag, "W/") {
+		return d.etag
+	}
+	return d.lastModified
+}
+
+// restart handles the server sending the whole picture when asked for the rest of it
+func (d *downloader) restart(resp *http.Response) error {
+	if d.reset == nil {
+		// what was written can't be taken back, skip it if the picture is the same
+		h := resp.Header
+		if (d.etag != "" && d.etag != h.Get("ETag")) || (d.lastModified != "" && d.lastModified != h.Get("Last-Modified")) {
+			return errors.New("picture changed during the download")
+		}
+		d.resumed = false
+		_, err := io.CopyN(io.Discard, resp.Body, d.n)
+		return err
+	}
+	if err := d.reset(); err != nil {
+		return err
+	}
+	d.hash.Reset()
+	d.n, d.head, d.ctype, d.resumed = 0, nil, "", false
+	return nil
+}
+
+// fetch requests rawurl from the bytes received so far and copies the response to d
+func (d *downloader) fetch(ctx context.Context, cl *http.Client, userAgent, rawurl string) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, rawurl, nil)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("User-Agent", userAgent)
+	if d.n > 0 {
+		req.Header.Set("Range", "bytes="+strconv.FormatInt(d.n, 10)+"-")
+		if v := d.ifRange(); v != "" {
+			req.Header.Set("If-Range", v)
+		}
+	}
+	resp, err := cl.Do(req)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = resp.Body.Close() }()
+
+	total := resp.ContentLength
+	switch {
+	case resp.StatusCode == http.StatusPartialContent && d.n > 0:
+		if start := rangeStart(resp.Header.Get("Content-Range")); start != d.n {
+			return fmt.Errorf("server resumed the download at byte %d, want %d", start, d.n)
+		}
+		d.resumed = true
+		if total > 0 {
+			total += d.n
+		}
+	case resp.StatusCode == http.StatusRequestedRangeNotSatisfiable && d.n > 0:
+		return nil // already complete
+	case resp.StatusCode == http.StatusOK:
+		if d.n > 0 {
+			// no Range support or the picture changed
+			if err := d.restart(resp); err != nil {
+				return err
+			}
+		}
+		d.etag, d.lastModified = resp.Header.Get("ETag"), resp.Header.Get("Last-Modified")
+	default:
+		body, _ := io.ReadAll(io.LimitReader(resp.Body, 1<<10))
+		return newAPIError(resp.StatusCode, resp.Header, body)
+	}
+	if total > d.o.maxSize {
+		return ErrDownloadTooLarge
+	}
+	_, err = io.Copy(d, resp.Body)
+	return err
+}
+
+// rangeStart returns the first byte of a Content-Range header e.g. "bytes 200-999/1000"
+func rangeStart(contentRange string) int64 {
+	s := strings.TrimPrefix(contentRange, "bytes ")
+	if i := strings.IndexByte(s, '-'); i > 0 {
+		if n, err := strconv.ParseInt(s[:i], 10, 64); err == nil {
+			return n
+		}
+	}
+	return -1
+}
+
+// Write implements io.Writer, it writes to the underlying writer once the
+// content type has been validated
+func (d *downloader) Write(p []byte) (int, error) {
+	if d.n+int64(len(p)) > d.o.maxSize {
+		return 0, ErrDownloadTooLarge
+	}
+	d.n += int64(len(p))
+	d.hash.Write(p)
+	if d.ctype != "" {
+		return d.w.Write(p)
+	}
+	d.head = append(d.head, p...)
+	if len(d.head) < sniffLen {
+		return len(p), nil
+	}
+	if err := d.flush(); err != nil {
+		return 0, err
+	}
+	return len(p), nil
+}
+
+// flush validates the held back bytes and writes them
+func (d *downloader) flush() error {
+	ctype, err := d.validate(d.head)
+	if err != nil {
+		return err
+	}
+	d.ctype = ctype
+	if _, err := d.w.Write(d.head); err != nil {
+		return err
+	}
+	d.head = nil
+	return nil
+}
+
+// finish flushes downloads shorter than sniffLen
+func (d *downloader) finish() error {
+	if d.ctype != "" {
+		return nil
+	}
+	if len(d.head) == 0 {
+		return fmt.Errorf("%w: empty download", ErrUnexpectedMimeType)
+	}
+	return d.flush()
+}
+
+// validate returns the content type of a file starting with head, an error if it's not allowed
+func (d *downloader) validate(head []byte) (string, error) {
+	if len(head) > sniffLen {
+		head = head[:sniffLen]
+	}
+	ctype := http.DetectContentType(head)
+	if i := strings.IndexByte(ctype, ';'); i >= 0 {
+		ctype = ctype[:i]
+	}
+	for _, t := range d.o.types {
+		if t == ctype {
+			return ctype, nil
+		}
+	}
+	return "", fmt.Errorf("%w: got %s", ErrUnexpectedMimeType, ctype)
+}
+
+func (d *downloader) result(rawurl string) *DownloadResult {
+	return &DownloadResult{
+		URL:         rawurl,
+		ContentType: d.ctype,
+		Size:        d.n,
+		SHA256:      hex.EncodeToString(d.hash.Sum(nil)),
+		Resumed:     d.resumed,
+	}
+}
@@ -0,0 +1,174 @@
+package nasa
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestDownloadImage(t *testing.T) {
+	c := NewClient(WithRetryPolicy(RetryPolicy{MaxAttempts: 3, BaseDelay: time.Millisecond}))
+	apod, err := c.APOD(time.Date(2017, 5, 11, 0, 0, 0, 0, time.UTC))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var buf bytes.Buffer
+	res, err := c.DownloadImage(*apod, &buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	sum := sha256.Sum256(buf.Bytes())
+	want := hex.EncodeToString(sum[:])
+	if res.ContentType != "image/jpeg" || res.Size != int64(buf.Len()) || res.SHA256 != want || res.Resumed {
+		t.Errorf("DownloadImage returned wrong result got %+v, want %d bytes of image/jpeg with sha256 %s", res, buf.Len(), want)
+	}
+
+	dir := t.TempDir()
+	tests := []struct {
+		name    string
+		prepare func(path string)
+		resumed bool
+	}{
+		{"complete", func(string) {}, false},
+		{"dropped connection", func(string) { fakeAPI.TruncateImages(1) }, true},
+		{"partial file", func(path string) {
+			writePart(t, path, buf.Bytes()[:1000], partInfo{URL: apod.HDURL})
+		}, true},
+		{"partial file of the same version", func(path string) {
+			writePart(t, path, buf.Bytes()[:1000], partInfo{URL: apod.HDURL, LastModified: "Thu, 11 May 2017 00:00:00 GMT"})
+		}, true},
+		{"partial file of another version", func(path string) {
+			writePart(t, path, bytes.Repeat([]byte{0xff, 0xd8, 0xff, 0xe0}, 250), partInfo{URL: apod.HDURL, LastModified: "Mon, 01 May 2017 00:00:00 GMT"})
+		}, false},
+		{"partial file of another URL", func(path string) {
+			writePart(t, path, buf.Bytes()[:1000], partInfo{URL: apod.URL})
+		}, false},
+		{"partial file without its info", func(path string) {
+			if err := os.WriteFile(path+".part", buf.Bytes()[:1000], 0644); err != nil {
+				t.Fatal(err)
+			}
+		}, false},
+		{"partial file of another type", func(path string) {
+			if err := os.WriteFile(path+".part", bytes.Repeat([]byte("<html>"), 200), 0644); err != nil {
+				t.Fatal(err)
+			}
+		}, false},
+	}
+	for i, v := range tests {
+		path := filepath.Join(dir, fmt.Sprintf("%d.jpg", i))
+		v.prepare(path)
+		res, err := c.DownloadImageFile(*apod, path)
+		if err != nil {
+			t.Errorf("DownloadImageFile %s returned error: %v", v.name, err)
+			continue
+		}
+		if res.SHA256 != want || res.Resumed != v.resumed {
+			t.Errorf("DownloadImageFile %s returned wrong result got %+v, want sha256 %s and resumed %t", v.name, res, want, v.resumed)
+		}
+		dat, err := os.ReadFile(path)
+		if err != nil || !bytes.Equal(dat, buf.Bytes()) {
+			t.Errorf("DownloadImageFile %s wrote wrong file (err %v)", v.name, err)
+		}
+		if _, err := os.Stat(path + ".part"); !os.IsNotExist(err) {
+			t.Errorf("DownloadImageFile %s left the partial file", v.name)
+		}
+		if _, err := os.Stat(path + ".part.json"); !os.IsNotExist(err) {
+			t.Errorf("DownloadImageFile %s left the partial file's info", v.name)
+		}
+	}
+}
+
+// writePart writes an interrupted download of path
+func writePart(t *testing.T, path string, dat []byte, info partInfo) {
+	t.Helper()
+	if err := os.WriteFile(path+".part", dat, 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := writePartInfo(path+".part.json", info); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestDownloadImageFileOtherPart(t *testing.T) {
+	// an interrupted download isn't resumed by the next picture saved to the same path
+	c := NewClient(WithRetryPolicy(NoRetry))
+	path := filepath.Join(t.TempDir(), "wallpaper.jpg")
+	var apods []*Image
+	for _, d := range []time.Time{time.Date(2017, 5, 11, 0, 0, 0, 0, time.UTC), time.Date(2016, 1, 3, 0, 0, 0, 0, time.UTC)} {
+		apod, err := c.APOD(d)
+		if err != nil {
+			t.Fatal(err)
+		}
+		apods = append(apods, apod)
+	}
+	fakeAPI.TruncateImages(1)
+	if _, err := c.DownloadImageFile(*apods[0], path); err == nil {
+		t.Fatal("DownloadImageFile returned no error for a dropped connection")
+	}
+	if _, err := os.Stat(path + ".part.json"); err != nil {
+		t.Fatalf("DownloadImageFile didn't keep the partial file's info: %v", err)
+	}
+
+	res, err := c.DownloadImageFile(*apods[1], path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var buf bytes.Buffer
+	if _, err := c.DownloadImage(*apods[1], &buf); err != nil {
+		t.Fatal(err)
+	}
+	sum := sha256.Sum256(buf.Bytes())
+	if want := hex.EncodeToString(sum[:]); res.SHA256 != want || res.Resumed {
+		t.Errorf("DownloadImageFile after another picture's partial file returned wrong result got %+v, want sha256 %s and not resumed", res, want)
+	}
+}
+
+func TestDownloadImageFailures(t *testing.T) {
+	c := NewClient(WithRetryPolicy(NoRetry))
+	html := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, "<html><body>Not a picture</body></html>")
+	}))
+	defer html.Close()
+	apod, err := c.APOD(time.Date(2017, 5, 11, 0, 0, 0, 0, time.UTC))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		apod Image
+		opts []DownloadOption
+		err  error
+	}{
+		{Image{MediaType: MediaImage, URL: html.URL}, nil, ErrUnexpectedMimeType},
+		{*apod, []DownloadOption{DownloadMaxSize(100)}, ErrDownloadTooLarge},
+		{Image{MediaType: MediaVideo, URL: "https://example.com/page"}, nil, ErrNoPicture},
+	}
+	dir := t.TempDir()
+	for i, v := range tests {
+		path := filepath.Join(dir, fmt.Sprintf("%d.jpg", i))
+		if _, err := c.DownloadImageFile(v.apod, path, v.opts...); !errors.Is(err, v.err) {
+			t.Errorf("DownloadImageFile returned wrong error got %v, want %v", err, v.err)
+		}
+		if _, err := os.Stat(path); !os.IsNotExist(err) {
+			t.Errorf("DownloadImageFile created %s on failure", path)
+		}
+	}
+
+	path := filepath.Join(dir, "missing.jpg")
+	_, err = c.DownloadImageFile(Image{MediaType: MediaImage, URL: fakeAPI.URL + "/image/bad"}, path)
+	var apiErr *APIError
+	if !errors.As(err, &apiErr) || apiErr.StatusCode != http.StatusNotFound {
+		t.Errorf("DownloadImageFile returned wrong error got %v, want a %d APIError", err, http.StatusNotFound)
+	}
+	if _, err := os.Stat(path + ".part"); !os.IsNotExist(err) {
+		t.Errorf("DownloadImageFile left an empty partial file")
+	}
+}
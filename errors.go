@@ -0,0 +1,103 @@
+package nasa
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Sentinel errors returned (wrapped) by the API calls. Check for them with errors.Is
+var (
+	ErrRateLimited    = errors.New("nasa: API rate limit exceeded")
+	ErrInvalidKey     = errors.New("nasa: invalid or missing API key")
+	ErrDateOutOfRange = errors.New("nasa: date out of range")
+	ErrUpstreamDown   = errors.New("nasa: NASA API unavailable")
+)
+
+// APIError is returned when the NASA API responds with a non 200 status
+type APIError struct {
+	StatusCode int    // HTTP status code
+	Code       string // NASA error code if any, e.g. OVER_RATE_LIMIT, API_KEY_INVALID
+	Message    string // NASA error message, or the http status text
+
+	RetryAfter time.Duration // delay requested by the Retry-After header, if any
+
+	kind error // matching sentinel error, if any
+}
+
+func (e *APIError) Error() string {
+	if e.Code != "" {
+		return fmt.Sprintf("nasa: API error %d %s: %s", e.StatusCode, e.Code, e.Message)
+	}
+	return fmt.Sprintf("nasa: API error %d: %s", e.StatusCode, e.Message)
+}
+
+// Is reports whether the error matches one of the sentinel errors e.g. ErrRateLimited
+func (e *APIError) Is(target error) bool {
+	return e.kind != nil && target == e.kind
+}
+
+// errorBody covers the error bodies returned by api.nasa.gov, the APOD service
+// and the NeoWs service
+type errorBody struct {
+	Error struct {
+		Code    string `json:"code"`
+		Message string `json:"message"`
+	} `json:"error"`
+	Msg          string `json:"msg"`           // APOD
+	HTTPError    string `json:"http_error"`    // NeoWs
+	ErrorMessage string `json:"error_message"` // NeoWs
+}
+
+// newAPIError creates an APIError from a non 200 response and its body
+func newAPIError(status int, h http.Header, body []byte) *APIError {
+	e := &APIError{StatusCode: status, RetryAfter: retryAfter(h.Get("Retry-After"))}
+	var eb errorBody
+	if err := json.Unmarshal(body, &eb); err == nil {
+		switch {
+		case eb.Error.Code != "":
+			e.Code, e.Message = eb.Error.Code, eb.Error.Message
+		case eb.Msg != "":
+			e.Message = eb.Msg
+		case eb.ErrorMessage != "":
+			e.Code, e.Message = eb.HTTPError, eb.ErrorMessage
+		}
+	}
+	if e.Message == "" {
+		e.Message = http.StatusText(status)
+	}
+
+	msg := strings.ToLower(e.Message)
+	switch {
+	case status == http.StatusTooManyRequests || e.Code == "OVER_RATE_LIMIT":
+		e.kind = ErrRateLimited
+	case strings.HasPrefix(e.Code, "API_KEY_"):
+		e.kind = ErrInvalidKey
+	case status == http.StatusBadRequest &&
+		(strings.Contains(msg, "must be between") || strings.Contains(msg, "date limit")):
+		e.kind = ErrDateOutOfRange
+	case status >= http.StatusInternalServerError:
+		e.kind = ErrUpstreamDown
+	}
+	return e
+}
+
+// retryAfter parses a Retry-After header value, either seconds or an http date
+func retryAfter(v string) time.Duration {
+	if v == "" {
+		return 0
+	}
+	if secs, err := strconv.Atoi(v); err == nil && secs > 0 {
+		return time.Duration(secs) * time.Second
+	}
+	if t, err := http.ParseTime(v); err == nil {
+		if d := time.Until(t); d > 0 {
+			return d
+		}
+	}
+	return 0
+}
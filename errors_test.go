@@ -0,0 +1,48 @@
+package nasa
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestAPIErrors(t *testing.T) {
+	tests := []struct {
+		status int
+		body   string
+		code   string
+		want   error
+	}{
+		{http.StatusTooManyRequests, `{"error":{"code":"OVER_RATE_LIMIT","message":"You have exceeded your rate limit."}}`, "OVER_RATE_LIMIT", ErrRateLimited},
+		{http.StatusForbidden, `{"error":{"code":"API_KEY_INVALID","message":"An invalid api_key was supplied."}}`, "API_KEY_INVALID", ErrInvalidKey},
+		{http.StatusBadRequest, `{"code":400,"msg":"Date must be between Jun 16, 1995 and May 12, 2017.","service_version":"v1"}`, "", ErrDateOutOfRange},
+		{http.StatusBadRequest, `{"code":400,"http_error":"BAD_REQUEST","error_message":"Date Format Exception - Expected format (yyyy-mm-dd) - The Feed date limit is only 7 Days"}`, "BAD_REQUEST", ErrDateOutOfRange},
+		{http.StatusBadGateway, `<html>Bad Gateway</html>`, "", ErrUpstreamDown},
+	}
+	for _, v := range tests {
+		ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(v.status)
+			_, _ = w.Write([]byte(v.body))
+		}))
+		c := NewClient(WithBaseURL(ts.URL), WithRetryPolicy(NoRetry))
+		_, err := c.APOD(time.Date(2017, 5, 11, 0, 0, 0, 0, time.UTC))
+		ts.Close()
+
+		if !errors.Is(err, v.want) {
+			t.Errorf("APOD returned wrong error for %d got %v, want %v", v.status, err, v.want)
+		}
+		var apiErr *APIError
+		if !errors.As(err, &apiErr) {
+			t.Errorf("APOD returned %T, want *APIError", err)
+			continue
+		}
+		if apiErr.StatusCode != v.status {
+			t.Errorf("APIError has wrong status got %d, want %d", apiErr.StatusCode, v.status)
+		}
+		if apiErr.Code != v.code {
+			t.Errorf("APIError has wrong code got %q, want %q", apiErr.Code, v.code)
+		}
+	}
+}
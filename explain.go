@@ -0,0 +1,324 @@
+package nasa
+
+import (
+	"context"
+	"fmt"
+	"html"
+	"io"
+	"net/http"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+	"unicode/utf8"
+)
+
+// Explanation is an APOD explanation parsed into its parts
+type Explanation struct {
+	Text    string   `json:"text"`              // explanation without credits, as plain text
+	Summary string   `json:"summary"`           // first sentence of the text
+	Credits []Credit `json:"credits,omitempty"` // photographers, copyright holders etc
+	Links   []Link   `json:"links,omitempty"`   // hyperlinks of HTML explanations, and bare URLs
+	Objects []string `json:"objects,omitempty"` // NGC, IC and Messier catalogue IDs e.g. "NGC 4594", "M104"
+}
+
+// Credit is a credit of an APOD, e.g. Role "Image Credit & Copyright", Name "Ken Crawford"
+type Credit struct {
+	Role string `json:"role"`
+	Name string `json:"name"`
+}
+
+func (c Credit) String() string { return c.Role + ": " + c.Name }
+
+// Link is a hyperlink of an explanation
+type Link struct {
+	Text string `json:"text"`
+	URL  string `json:"url"`
+}
+
+// ParsedExplanation returns the APOD's explanation parsed with ParseExplanation.
+// The copyright holder is added to the credits if the explanation doesn't credit them.
+// See ExplainAPOD to fall back to the APOD's page when the explanation is unusable.
+func (ni Image) ParsedExplanation() Explanation {
+	e := ParseExplanation(ni.Explanation)
+	e.addCopyright(ni.Copyright)
+	return e
+}
+
+// addCopyright adds the copyright holder to the credits, if they're not credited
+func (e *Explanation) addCopyright(copyright string) {
+	c := strings.Join(strings.Fields(copyright), " ")
+	if c == "" {
+		return
+	}
+	for _, cr := range e.Credits {
+		if strings.Contains(cr.Name, c) {
+			return
+		}
+	}
+	e.Credits = append(e.Credits, Credit{Role: "Copyright", Name: c})
+}
+
+// ExplainAPOD returns the parsed explanation of the APOD using DefaultClient.
+// See Client.ExplainAPODContext.
+func ExplainAPOD(apod Image) (Explanation, error) {
+	return DefaultClient.ExplainAPODContext(context.Background(), apod)
+}
+
+// ExplainAPODContext is like ExplainAPOD but uses ctx for the request
+func ExplainAPODContext(ctx context.Context, apod Image) (Explanation, error) {
+	return DefaultClient.ExplainAPODContext(ctx, apod)
+}
+
+// ExplainAPOD returns the parsed explanation of the APOD
+func (c *Client) ExplainAPOD(apod Image) (Explanation, error) {
+	return c.ExplainAPODContext(context.Background(), apod)
+}
+
+// ExplainAPODContext is like ExplainAPOD but uses ctx for the request. It returns
+// apod.ParsedExplanation(), unless the explanation from the API is empty or garbled.
+// Then the APOD's page on apod.nasa.gov is parsed with ParseExplanationHTML instead,
+// which also keeps the explanation's hyperlinks. Pages are cached with the client's cache.
+// If the page can't be fetched, the parsed explanation is returned with the error.
+func (c *Client) ExplainAPODContext(ctx context.Context, apod Image) (Explanation, error) {
+	if usableExplanation(apod.Explanation) {
+		return apod.ParsedExplanation(), nil
+	}
+	page, err := c.apodPage(ctx, apod.Date)
+	if err != nil {
+		return apod.ParsedExplanation(), fmt.Errorf("unable to get the APOD page of %s: %w", apod.Date, err)
+	}
+	e := ParseExplanationHTML(page)
+	e.addCopyright(apod.Copyright)
+	return e, nil
+}
+
+// mojibakeRE matches UTF-8 text decoded as Latin-1 or Windows-1252, e.g. â€™ for ’
+var mojibakeRE = regexp.MustCompile(`Ã[\x{80}-\x{bf}]|â€`)
+
+// usableExplanation reports whether an explanation from the API can be parsed,
+// rather than being empty or garbled by a wrong character encoding
+func usableExplanation(s string) bool {
+	return strings.TrimSpace(s) != "" && utf8.ValidString(s) &&
+		!strings.ContainsRune(s, utf8.RuneError) && !mojibakeRE.MatchString(s)
+}
+
+// maxPageSize is the largest APOD page read
+const maxPageSize = 1 << 20
+
+// apodPage returns the HTML page of the APOD of d, as UTF-8
+func (c *Client) apodPage(ctx context.Context, d Date) (string, error) {
+	rawurl := c.pageURL(d)
+	key := "page:" + rawurl
+	if c.cache != nil {
+		if dat, ok := c.cache.Get(key); ok {
+			return string(dat), nil
+		}
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, rawurl, nil)
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("User-Agent", c.userAgent)
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return "", err
+	}
+	defer func() { _ = resp.Body.Close() }()
+	dat, err := io.ReadAll(io.LimitReader(resp.Body, maxPageSize))
+	if err != nil {
+		return "", err
+	}
+	if resp.StatusCode != http.StatusOK {
+		return "", newAPIError(resp.StatusCode, resp.Header, dat)
+	}
+	if !utf8.Valid(dat) {
+		// older pages are Latin-1
+		runes := make([]rune, len(dat))
+		for i, b := range dat {
+			runes[i] = rune(b)
+		}
+		dat = []byte(string(runes))
+	}
+	if c.cache != nil {
+		ttl := time.Duration(0)
+		if d == LatestAPODDate() {
+			ttl = CacheTTLRecent // today's page may still be updated
+		}
+		c.cache.Set(key, dat, ttl)
+	}
+	return string(dat), nil
+}
+
+// explanationEnd are the phrases following the explanation on APOD pages, and
+// sometimes in explanations from the API
+var explanationEnd = []string{
+	"Tomorrow's picture:",
+	"Explore Your Universe:",
+	"Almost Hyperspace:",
+	"Follow APOD on",
+	"Notable APOD Image Submissions:",
+}
+
+var (
+	creditRE  = regexp.MustCompile(`(?i)\b(?:(?:image|video|illustration|animation|photo|data|text|music|processing|composite)(?:\s*(?:&|and))?\s+)?(?:credits?|copyright)(?:\s*(?:&|and)\s*(?:copyright|licence|license))?\s*:\s*`)
+	urlRE     = regexp.MustCompile(`https?://[^\s<>"]+`)
+	objectRE  = regexp.MustCompile(`\b(NGC|IC|M|Messier)[ \-]?(\d{1,4})([A-Da-d])?\b`)
+	anchorRE  = regexp.MustCompile(`(?is)<a\s[^>]*?href\s*=\s*["']?([^"'\s>]+)["']?[^>]*>(.*?)</a\s*>`)
+	tagRE     = regexp.MustCompile(`(?s)<[^>]*>`)
+	sectionRE = regexp.MustCompile(`(?i)\bExplanation\s*:`)
+)
+
+// ParseExplanation parses a plain text APOD explanation, as returned by the API.
+// Credits are the text following labels such as "Image Credit:" or "Copyright:".
+func ParseExplanation(text string) Explanation {
+	text = strings.Join(strings.Fields(text), " ")
+	var e Explanation
+
+	// an "Explanation:" label, as on APOD pages, ends the credits
+	if loc := sectionRE.FindStringIndex(text); loc != nil {
+		e.Credits = parseCredits(text[:loc[0]])
+		text = strings.TrimSpace(text[loc[1]:])
+	}
+	for _, end := range explanationEnd {
+		if i := strings.Index(text, end); i >= 0 {
+			text = strings.TrimSpace(text[:i])
+		}
+	}
+	if loc := creditRE.FindStringIndex(text); loc != nil {
+		e.Credits = append(e.Credits, parseCredits(text[loc[0]:])...)
+		text = strings.TrimSpace(text[:loc[0]])
+	}
+	e.Text = text
+	e.Summary = firstSentence(text)
+	e.Objects = catalogueObjects(text)
+	for _, u := range urlRE.FindAllString(text, -1) {
+		u = strings.TrimRight(u, ".,;:!?)")
+		e.Links = append(e.Links, Link{Text: u, URL: u})
+	}
+	return e
+}
+
+// ParseExplanationHTML parses an APOD explanation in HTML, or a whole APOD page, keeping
+// its hyperlinks. Relative links are resolved against https://apod.nasa.gov/apod/.
+func ParseExplanationHTML(s string) Explanation {
+	var links []Link
+	s = anchorRE.ReplaceAllStringFunc(s, func(a string) string {
+		m := anchorRE.FindStringSubmatch(a)
+		text := strings.Join(strings.Fields(html.UnescapeString(tagRE.ReplaceAllString(m[2], " "))), " ")
+		links = append(links, Link{Text: text, URL: resolveAPODLink(html.UnescapeString(m[1]))})
+		return " " + m[2] + " "
+	})
+	text := html.UnescapeString(tagRE.ReplaceAllString(s, " "))
+	e := ParseExplanation(text)
+
+	// keep the links of the explanation, not those of the credits or page
+	var keep []Link
+	for _, l := range links {
+		if l.Text != "" && strings.Contains(e.Text, l.Text) {
+			keep = append(keep, l)
+		}
+	}
+	e.Links = append(keep, e.Links...)
+	return e
+}
+
+// resolveAPODLink resolves a link of an APOD page
+func resolveAPODLink(u string) string {
+	switch {
+	case strings.Contains(u, "://"), strings.HasPrefix(u, "mailto:"):
+		return u
+	case strings.HasPrefix(u, "//"):
+		return "https:" + u
+	case strings.HasPrefix(u, "/"):
+		return "https://apod.nasa.gov" + u
+	}
+	return apodPageBase + u
+}
+
+// parseCredits returns the credits in s, labelled as matched by creditRE
+func parseCredits(s string) []Credit {
+	var credits []Credit
+	locs := creditRE.FindAllStringIndex(s, -1)
+	for i, loc := range locs {
+		end := len(s)
+		if i+1 < len(locs) {
+			end = locs[i+1][0]
+		}
+		name := strings.TrimSpace(strings.TrimRight(strings.TrimSpace(s[loc[1]:end]), ";,"))
+		if name == "" {
+			continue
+		}
+		role := strings.TrimSpace(strings.TrimSuffix(strings.TrimSpace(s[loc[0]:loc[1]]), ":"))
+		credits = append(credits, Credit{Role: role, Name: name})
+	}
+	return credits
+}
+
+// abbreviations are words ending in a period that don't end a sentence
+var abbreviations = map[string]bool{
+	"approx": true, "ca": true, "dr": true, "e.g": true, "etc": true, "i.e": true, "jr": true,
+	"mr": true, "mrs": true, "ms": true, "mt": true, "no": true, "prof": true, "sr": true,
+	"st": true, "vs": true,
+}
+
+// firstSentence returns the first sentence of text
+func firstSentence(text string) string {
+	for i := 0; i < len(text); i++ {
+		c := text[i]
+		if c != '.' && c != '!' && c != '?' {
+			continue
+		}
+		// a sentence ends at punctuation followed by a space and a capital, digit or quote
+		j := i + 1
+		for j < len(text) && (text[j] == '"' || text[j] == '\'' || text[j] == ')') {
+			j++
+		}
+		if j >= len(text) {
+			break
+		}
+		if text[j] != ' ' || j+1 >= len(text) {
+			continue
+		}
+		next := rune(text[j+1])
+		if !unicode.IsUpper(next) && !unicode.IsDigit(next) && next != '"' && next != '\'' {
+			continue
+		}
+		if c == '.' {
+			word := text[strings.LastIndexByte(text[:i], ' ')+1 : i]
+			if abbreviations[strings.ToLower(word)] || (len(word) == 1 && unicode.IsUpper(rune(word[0]))) {
+				continue // e.g. "Dr. Smith" or the initial of "J. Smith"
+			}
+		}
+		return text[:j]
+	}
+	return text
+}
+
+// catalogueObjects returns the NGC, IC and Messier objects in text, without duplicates
+func catalogueObjects(text string) []string {
+	var objects []string
+	seen := make(map[string]bool)
+	for _, m := range objectRE.FindAllStringSubmatch(text, -1) {
+		n, _ := strconv.Atoi(m[2])
+		var id string
+		switch m[1] {
+		case "M", "Messier":
+			if n < 1 || n > 110 || m[3] != "" {
+				continue
+			}
+			id = "M" + strconv.Itoa(n)
+		default:
+			if n < 1 {
+				continue
+			}
+			id = m[1] + " " + strconv.Itoa(n) + strings.ToUpper(m[3])
+		}
+		if !seen[id] {
+			seen[id] = true
+			objects = append(objects, id)
+		}
+	}
+	return objects
+}
@@ -0,0 +1,152 @@
+package nasa
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/peteretelej/nasa/nasatest"
+)
+
+func TestParseExplanation(t *testing.T) {
+	tests := []struct {
+		text    string
+		summary string
+		objects []string
+		credits []Credit
+	}{
+		{
+			"This floating ring is the size of a galaxy. In fact, it is part of the photogenic Sombrero Galaxy, also known as M104 and NGC 4594.",
+			"This floating ring is the size of a galaxy.",
+			[]string{"M104", "NGC 4594"}, nil,
+		},
+		{
+			"What's happened to our Sun? Nothing very unusual: it just threw a filament.",
+			"What's happened to our Sun?", nil, nil,
+		},
+		{
+			"Discovered by Dr. E. E. Barnard in 1913, the dark nebula Barnard 33 lies in front of IC 434. The Horsehead (M 42 is nearby) and Messier 43 too.",
+			"Discovered by Dr. E. E. Barnard in 1913, the dark nebula Barnard 33 lies in front of IC 434.",
+			[]string{"IC 434", "M42", "M43"}, nil,
+		},
+		{
+			"Galaxies NGC 4038 and NGC 4039, not M200 nor M 0, are seen here.  Image Credit: NASA, ESA; Processing & Copyright: Jane Doe Tomorrow's picture: open space",
+			"Galaxies NGC 4038 and NGC 4039, not M200 nor M 0, are seen here.",
+			[]string{"NGC 4038", "NGC 4039"},
+			[]Credit{{"Image Credit", "NASA, ESA"}, {"Processing & Copyright", "Jane Doe"}},
+		},
+		{"", "", nil, nil},
+	}
+	for _, v := range tests {
+		e := ParseExplanation(v.text)
+		if e.Summary != v.summary {
+			t.Errorf("ParseExplanation(%q) returned wrong summary got %q, want %q", v.text, e.Summary, v.summary)
+		}
+		if !reflect.DeepEqual(e.Objects, v.objects) {
+			t.Errorf("ParseExplanation(%q) returned wrong objects got %q, want %q", v.text, e.Objects, v.objects)
+		}
+		if !reflect.DeepEqual(e.Credits, v.credits) {
+			t.Errorf("ParseExplanation(%q) returned wrong credits got %q, want %q", v.text, e.Credits, v.credits)
+		}
+		if strings.Contains(e.Text, "Credit") || strings.Contains(e.Text, "Tomorrow") {
+			t.Errorf("ParseExplanation(%q) returned wrong text got %q, want no credits or trailer", v.text, e.Text)
+		}
+	}
+}
+
+const apodPageHTML = `<html><body>
+<center>
+<b> The Horsehead Nebula </b> <br>
+<b> Image Credit &amp; <a href="lib/about_apod.html#srapply">Copyright</a>: </b>
+<a href="http://www.imagingdeepsky.com/">Ken Crawford</a>
+</center>
+<p>
+<b> Explanation: </b>
+One of the most identifiable nebulae in the sky, the
+<a href="http://en.wikipedia.org/wiki/Horsehead_Nebula">Horsehead Nebula</a>
+in <a href="ap130101.html">Orion</a>, is part of a large, dark, molecular cloud.
+More at https://example.com/horsehead.
+<p>
+<center>
+<b> Tomorrow's picture: </b>dark nebulae
+</center>
+</body></html>`
+
+func TestParseExplanationHTML(t *testing.T) {
+	e := ParseExplanationHTML(apodPageHTML)
+	wantText := "One of the most identifiable nebulae in the sky, the Horsehead Nebula in Orion , is part of a large, dark, molecular cloud. More at https://example.com/horsehead."
+	if e.Text != wantText {
+		t.Errorf("ParseExplanationHTML returned wrong text got %q, want %q", e.Text, wantText)
+	}
+	wantCredits := []Credit{{"Image Credit & Copyright", "Ken Crawford"}}
+	if !reflect.DeepEqual(e.Credits, wantCredits) {
+		t.Errorf("ParseExplanationHTML returned wrong credits got %q, want %q", e.Credits, wantCredits)
+	}
+	wantLinks := []Link{
+		{"Horsehead Nebula", "http://en.wikipedia.org/wiki/Horsehead_Nebula"},
+		{"Orion", "https://apod.nasa.gov/apod/ap130101.html"},
+		{"https://example.com/horsehead", "https://example.com/horsehead"},
+	}
+	if !reflect.DeepEqual(e.Links, wantLinks) {
+		t.Errorf("ParseExplanationHTML returned wrong links got %q, want %q", e.Links, wantLinks)
+	}
+}
+
+func TestImageParsedExplanation(t *testing.T) {
+	apod, err := ApodImage(time.Date(2017, 5, 13, 0, 0, 0, 0, time.UTC))
+	if err != nil {
+		t.Fatal(err)
+	}
+	e := apod.ParsedExplanation()
+	want := []Credit{{"Copyright", "Mark Hanson"}}
+	if !reflect.DeepEqual(e.Credits, want) {
+		t.Errorf("ParsedExplanation returned wrong credits got %q, want %q", e.Credits, want)
+	}
+	if !strings.Contains(apod.String(), "Objects: M104, NGC 4594") {
+		t.Errorf("Image.String does not list the APOD's objects: %s", apod)
+	}
+	if got := NewSearchIndex([]Image{*apod}).Search(ParseQuery("NGC4594"), 0); len(got) != 1 {
+		t.Errorf("Search did not find the APOD by its catalogue ID got %v", got)
+	}
+}
+
+func TestExplainAPOD(t *testing.T) {
+	ts := nasatest.NewServer()
+	defer ts.Close()
+	day := time.Date(2013, 11, 8, 0, 0, 0, 0, time.UTC)
+	ts.AddPage(day, apodPageHTML)
+	latin1 := time.Date(1996, 3, 1, 0, 0, 0, 0, time.UTC)
+	ts.AddPage(latin1, "<b>Explanation:</b> The Caf\xe9 Nebula. <p><b>Tomorrow's picture:</b>")
+	c := NewClient(WithBaseURL(ts.URL), WithRetryPolicy(NoRetry), WithCache(NewMemoryCache(10)))
+
+	tests := []struct {
+		apod    Image
+		summary string
+		links   int
+	}{
+		{Image{Date: DateOf(day)}, "One of the most identifiable nebulae in the sky, the Horsehead Nebula in Orion , is part of a large, dark, molecular cloud.", 3},
+		{Image{Date: DateOf(day), Explanation: "Itâ€™s the Horsehead Nebula."}, "One of the most identifiable nebulae in the sky, the Horsehead Nebula in Orion , is part of a large, dark, molecular cloud.", 3},
+		{Image{Date: DateOf(day), Explanation: "It's the Horsehead Nebula. No page needed."}, "It's the Horsehead Nebula.", 0},
+		{Image{Date: DateOf(latin1)}, "The Café Nebula.", 0},
+	}
+	for _, v := range tests {
+		e, err := c.ExplainAPOD(v.apod)
+		if err != nil {
+			t.Errorf("ExplainAPOD(%q) returned error: %v", v.apod.Explanation, err)
+			continue
+		}
+		if e.Summary != v.summary || len(e.Links) != v.links {
+			t.Errorf("ExplainAPOD(%q) returned wrong explanation got %q with %d links, want %q with %d", v.apod.Explanation, e.Summary, len(e.Links), v.summary, v.links)
+		}
+	}
+
+	// pages are cached, and a missing page returns the API's explanation with the error
+	ts.Close()
+	if _, err := c.ExplainAPOD(Image{Date: DateOf(day)}); err != nil {
+		t.Errorf("ExplainAPOD did not use the cached page: %v", err)
+	}
+	if _, err := c.ExplainAPOD(Image{Date: DateOf(day).AddDays(1)}); err == nil {
+		t.Errorf("ExplainAPOD returned no error for a page that could not be fetched")
+	}
+}
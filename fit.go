@@ -0,0 +1,334 @@
+package nasa
+
+import (
+	"fmt"
+	"image"
+	"image/color"
+	"image/draw"
+	"math"
+)
+
+// FitMode is how a picture is fitted to a size of a different aspect ratio
+type FitMode string
+
+// Fit modes
+const (
+	// FitCrop fills the size, cropping the edges of the picture around its center
+	FitCrop FitMode = "crop"
+	// FitSmartCrop fills the size, cropping around the most detailed part of the picture
+	FitSmartCrop FitMode = "smart"
+	// FitLetterbox shows the whole picture over a blurred copy filling the rest of the size
+	FitLetterbox FitMode = "letterbox"
+)
+
+// ParseFitMode returns the FitMode named s
+func ParseFitMode(s string) (FitMode, error) {
+	switch m := FitMode(s); m {
+	case FitCrop, FitSmartCrop, FitLetterbox:
+		return m, nil
+	}
+	return "", fmt.Errorf("invalid fit mode %q, should be crop, smart or letterbox", s)
+}
+
+// letterbox background settings: the picture is shrunk by backgroundShrink before
+// blurring, which is cheaper than blurring at full size and blurs more
+const (
+	backgroundShrink = 16
+	backgroundBlur   = 2
+	backgroundDim    = 0.6
+)
+
+// smartCropSize is the longest side of the thumbnail the detail of a picture is measured on
+const smartCropSize = 128
+
+// Fit returns img resized to width x height. If either is 0 it's computed from the
+// other, keeping the aspect ratio. Otherwise the picture is fitted with mode.
+func Fit(img image.Image, width, height int, mode FitMode) *image.RGBA {
+	src := toRGBA(img)
+	b := src.Bounds()
+	if b.Empty() {
+		return image.NewRGBA(image.Rect(0, 0, width, height))
+	}
+	switch {
+	case width <= 0 && height <= 0:
+		width, height = b.Dx(), b.Dy()
+	case width <= 0:
+		width = max(1, int(math.Round(float64(height)*float64(b.Dx())/float64(b.Dy()))))
+	case height <= 0:
+		height = max(1, int(math.Round(float64(width)*float64(b.Dy())/float64(b.Dx()))))
+	}
+	switch mode {
+	case FitLetterbox:
+		return letterbox(src, width, height)
+	case FitSmartCrop:
+		return resize(src.SubImage(smartCrop(src, width, height)).(*image.RGBA), width, height)
+	default:
+		return resize(src.SubImage(centerCrop(b, width, height)).(*image.RGBA), width, height)
+	}
+}
+
+// toRGBA returns img as an *image.RGBA, converting it if needed
+func toRGBA(img image.Image) *image.RGBA {
+	if rgba, ok := img.(*image.RGBA); ok {
+		return rgba
+	}
+	return copyRGBA(img)
+}
+
+// copyRGBA returns a copy of img as an *image.RGBA
+func copyRGBA(img image.Image) *image.RGBA {
+	rgba := image.NewRGBA(img.Bounds())
+	draw.Draw(rgba, rgba.Bounds(), img, img.Bounds().Min, draw.Src)
+	return rgba
+}
+
+// cropSize returns the largest size of the aspect ratio of width x height within b
+func cropSize(b image.Rectangle, width, height int) (w, h int) {
+	w, h = b.Dx(), b.Dy()
+	if w*height > h*width {
+		w = max(1, h*width/height)
+	} else {
+		h = max(1, w*height/width)
+	}
+	return w, h
+}
+
+// centerCrop returns the center of b of the aspect ratio of width x height
+func centerCrop(b image.Rectangle, width, height int) image.Rectangle {
+	w, h := cropSize(b, width, height)
+	x, y := b.Min.X+(b.Dx()-w)/2, b.Min.Y+(b.Dy()-h)/2
+	return image.Rect(x, y, x+w, y+h)
+}
+
+// smartCrop returns the part of src of the aspect ratio of width x height with the most
+// detail, measured as the gradient of the luminance, slightly favoring the center
+func smartCrop(src *image.RGBA, width, height int) image.Rectangle {
+	b := src.Bounds()
+	w, h := cropSize(b, width, height)
+	if w == b.Dx() && h == b.Dy() {
+		return b
+	}
+	scale := float64(smartCropSize) / float64(max(b.Dx(), b.Dy()))
+	if scale > 1 {
+		scale = 1
+	}
+	tw, th := max(1, int(float64(b.Dx())*scale)), max(1, int(float64(b.Dy())*scale))
+	thumb := resize(src, tw, th)
+	lum := make([]float64, tw*th)
+	for y := 0; y < th; y++ {
+		for x := 0; x < tw; x++ {
+			p := thumb.Pix[y*thumb.Stride+x*4:]
+			lum[y*tw+x] = 0.2126*float64(p[0]) + 0.7152*float64(p[1]) + 0.0722*float64(p[2])
+		}
+	}
+	// detail summed along the axis the crop slides on
+	horizontal := w < b.Dx()
+	n := th
+	if horizontal {
+		n = tw
+	}
+	detail := make([]float64, n)
+	for y := 1; y < th-1; y++ {
+		for x := 1; x < tw-1; x++ {
+			gx := lum[y*tw+x+1] - lum[y*tw+x-1]
+			gy := lum[(y+1)*tw+x] - lum[(y-1)*tw+x]
+			g := math.Abs(gx) + math.Abs(gy)
+			if horizontal {
+				detail[x] += g
+			} else {
+				detail[y] += g
+			}
+		}
+	}
+	size, total := w, b.Dx()
+	if !horizontal {
+		size, total = h, b.Dy()
+	}
+	window := max(1, int(math.Round(float64(size)*float64(n)/float64(total))))
+	if window > n {
+		window = n
+	}
+	var sum float64
+	for i := 0; i < window; i++ {
+		sum += detail[i]
+	}
+	best, bestScore := 0, -1.0
+	last := n - window
+	for start := 0; start <= last; start++ {
+		if start > 0 {
+			sum += detail[start+window-1] - detail[start-1]
+		}
+		score := sum
+		if last > 0 {
+			off := math.Abs(float64(start)/float64(last) - 0.5) // 0 at the center, 0.5 at the edges
+			score *= 1 - 0.4*off
+		}
+		if score > bestScore {
+			best, bestScore = start, score
+		}
+	}
+	off := int(math.Round(float64(best) * float64(total) / float64(n)))
+	if off > total-size {
+		off = total - size
+	}
+	if horizontal {
+		return image.Rect(b.Min.X+off, b.Min.Y, b.Min.X+off+w, b.Min.Y+h)
+	}
+	return image.Rect(b.Min.X, b.Min.Y+off, b.Min.X+w, b.Min.Y+off+h)
+}
+
+// letterbox returns src fitted within width x height, over a dimmed and blurred
+// copy of src filling the rest
+func letterbox(src *image.RGBA, width, height int) *image.RGBA {
+	b := src.Bounds()
+	bg := resize(src.SubImage(centerCrop(b, width, height)).(*image.RGBA),
+		max(1, width/backgroundShrink), max(1, height/backgroundShrink))
+	for i := 0; i < 3; i++ {
+		boxBlur(bg, backgroundBlur)
+	}
+	for i := range bg.Pix {
+		if i%4 != 3 {
+			bg.Pix[i] = uint8(float64(bg.Pix[i]) * backgroundDim)
+		}
+	}
+	dst := resize(bg, width, height)
+
+	w, h := width, b.Dy()*width/b.Dx()
+	if h > height {
+		w, h = b.Dx()*height/b.Dy(), height
+	}
+	w, h = max(1, w), max(1, h)
+	fg := resize(src, w, h)
+	at := image.Pt((width-w)/2, (height-h)/2)
+	draw.Draw(dst, fg.Bounds().Add(at), fg, image.Point{}, draw.Over)
+	return dst
+}
+
+// resize returns src scaled to width x height, using a triangle filter as wide as
+// the scale when shrinking, so all source pixels count, and bilinear when enlarging
+func resize(src *image.RGBA, width, height int) *image.RGBA {
+	b := src.Bounds()
+	cols := contributions(b.Dx(), width)
+	rows := contributions(b.Dy(), height)
+
+	// resize horizontally into tmp, then vertically into dst
+	tmp := make([]float32, b.Dy()*width*4)
+	for y := 0; y < b.Dy(); y++ {
+		row := src.Pix[src.PixOffset(b.Min.X, b.Min.Y+y):]
+		for x, c := range cols {
+			var r, g, bl, a float32
+			for i, wt := range c.weights {
+				p := row[(c.start+i)*4:]
+				r += wt * float32(p[0])
+				g += wt * float32(p[1])
+				bl += wt * float32(p[2])
+				a += wt * float32(p[3])
+			}
+			t := tmp[(y*width+x)*4:]
+			t[0], t[1], t[2], t[3] = r, g, bl, a
+		}
+	}
+	dst := image.NewRGBA(image.Rect(0, 0, width, height))
+	for y, c := range rows {
+		for x := 0; x < width; x++ {
+			var r, g, bl, a float32
+			for i, wt := range c.weights {
+				t := tmp[((c.start+i)*width+x)*4:]
+				r += wt * t[0]
+				g += wt * t[1]
+				bl += wt * t[2]
+				a += wt * t[3]
+			}
+			p := dst.Pix[y*dst.Stride+x*4:]
+			p[0], p[1], p[2], p[3] = clamp8(r), clamp8(g), clamp8(bl), clamp8(a)
+		}
+	}
+	return dst
+}
+
+// contribution is the source pixels making up a resized pixel, and their weights
+type contribution struct {
+	start   int
+	weights []float32
+}
+
+// contributions returns the contributions of in source pixels to each of out pixels
+func contributions(in, out int) []contribution {
+	scale := float64(in) / float64(out)
+	support := math.Max(scale, 1) // radius of the filter, in source pixels
+	cs := make([]contribution, out)
+	for i := range cs {
+		center := (float64(i)+0.5)*scale - 0.5
+		lo := int(math.Ceil(center - support))
+		hi := int(math.Floor(center + support))
+		if lo < 0 {
+			lo = 0
+		}
+		if hi > in-1 {
+			hi = in - 1
+		}
+		if hi < lo {
+			hi = lo
+		}
+		ws := make([]float32, hi-lo+1)
+		var sum float32
+		for j := lo; j <= hi; j++ {
+			wt := float32(1 - math.Abs(float64(j)-center)/support)
+			if wt < 0 {
+				wt = 0
+			}
+			ws[j-lo] = wt
+			sum += wt
+		}
+		if sum == 0 {
+			ws[0], sum = 1, 1
+		}
+		for j := range ws {
+			ws[j] /= sum
+		}
+		cs[i] = contribution{lo, ws}
+	}
+	return cs
+}
+
+func clamp8(v float32) uint8 {
+	switch {
+	case v <= 0:
+		return 0
+	case v >= 255:
+		return 255
+	}
+	return uint8(v + 0.5)
+}
+
+// boxBlur blurs img in place with a box of the given radius, clamping at the edges
+func boxBlur(img *image.RGBA, radius int) {
+	b := img.Bounds()
+	w, h := b.Dx(), b.Dy()
+	line := make([]color.RGBA, max(w, h))
+	blurLine := func(n int, at func(i int) []uint8) {
+		for i := 0; i < n; i++ {
+			p := at(i)
+			line[i] = color.RGBA{p[0], p[1], p[2], p[3]}
+		}
+		for i := 0; i < n; i++ {
+			var r, g, bl, a, cnt int
+			for j := i - radius; j <= i+radius; j++ {
+				k := min(max(j, 0), n-1)
+				r += int(line[k].R)
+				g += int(line[k].G)
+				bl += int(line[k].B)
+				a += int(line[k].A)
+				cnt++
+			}
+			p := at(i)
+			p[0], p[1], p[2], p[3] = uint8(r/cnt), uint8(g/cnt), uint8(bl/cnt), uint8(a/cnt)
+		}
+	}
+	for y := 0; y < h; y++ {
+		blurLine(w, func(x int) []uint8 { return img.Pix[img.PixOffset(b.Min.X+x, b.Min.Y+y):] })
+	}
+	for x := 0; x < w; x++ {
+		blurLine(h, func(y int) []uint8 { return img.Pix[img.PixOffset(b.Min.X+x, b.Min.Y+y):] })
+	}
+}
@@ -0,0 +1,130 @@
+package nasa
+
+import (
+	"bytes"
+	"errors"
+	"image"
+	"image/color"
+	"image/gif"
+	"image/jpeg"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/peteretelej/nasa/nasatest"
+)
+
+func TestFit(t *testing.T) {
+	src := nasatest.Image(time.Date(2017, 5, 11, 0, 0, 0, 0, time.UTC), 400, 200)
+	tests := []struct {
+		w, h  int
+		mode  FitMode
+		wantW int
+		wantH int
+	}{
+		{100, 100, FitCrop, 100, 100},
+		{300, 100, FitSmartCrop, 300, 100},
+		{160, 90, FitLetterbox, 160, 90},
+		{800, 0, FitCrop, 800, 400},
+		{0, 50, FitLetterbox, 100, 50},
+	}
+	for _, v := range tests {
+		got := Fit(src, v.w, v.h, v.mode).Bounds()
+		if got.Dx() != v.wantW || got.Dy() != v.wantH {
+			t.Errorf("Fit(%d, %d, %s) returned wrong size got %v, want %dx%d", v.w, v.h, v.mode, got.Size(), v.wantW, v.wantH)
+		}
+	}
+
+	// resizing keeps flat colors
+	flat := image.NewUniform(color.RGBA{10, 200, 30, 255})
+	img := Fit(&image.RGBA{}, 0, 0, FitCrop)
+	if !img.Bounds().Empty() {
+		t.Errorf("Fit of an empty image returned %v", img.Bounds())
+	}
+	fitted := Fit(&imageOf{flat, image.Rect(0, 0, 333, 77)}, 50, 50, FitCrop)
+	if c := fitted.RGBAAt(25, 25); c != (color.RGBA{10, 200, 30, 255}) {
+		t.Errorf("Fit returned wrong color got %v, want %v", c, flat.C)
+	}
+}
+
+// imageOf is an image of a color of the given bounds
+type imageOf struct {
+	*image.Uniform
+	b image.Rectangle
+}
+
+func (img *imageOf) Bounds() image.Rectangle { return img.b }
+
+func TestFitSmartCrop(t *testing.T) {
+	// a flat image with a checkered square near its right edge
+	src := image.NewRGBA(image.Rect(0, 0, 400, 100))
+	for y := 0; y < 100; y++ {
+		for x := 0; x < 400; x++ {
+			c := color.RGBA{20, 20, 40, 255}
+			if x >= 300 && x < 380 && y >= 10 && y < 90 && (x/4+y/4)%2 == 0 {
+				c = color.RGBA{255, 255, 255, 255}
+			}
+			src.SetRGBA(x, y, c)
+		}
+	}
+	if r := smartCrop(src, 1, 1); r.Min.X < 280 || r.Max.X > 400 {
+		t.Errorf("smartCrop returned wrong crop got %v, want around the detail at x 300-380", r)
+	}
+	if r := centerCrop(src.Bounds(), 1, 1); r != image.Rect(150, 0, 250, 100) {
+		t.Errorf("centerCrop returned wrong crop got %v, want %v", r, image.Rect(150, 0, 250, 100))
+	}
+}
+
+func TestFitLetterbox(t *testing.T) {
+	src := image.NewRGBA(image.Rect(0, 0, 100, 100))
+	for i := range src.Pix {
+		src.Pix[i] = 200
+		if i%4 == 3 {
+			src.Pix[i] = 255
+		}
+	}
+	dst := Fit(src, 300, 100, FitLetterbox)
+	if c := dst.RGBAAt(150, 50); c.R != 200 {
+		t.Errorf("Fit returned wrong picture color got %v, want %d", c, 200)
+	}
+	if c := dst.RGBAAt(5, 50); c.R != uint8(200*backgroundDim) || c.A != 255 {
+		t.Errorf("Fit returned wrong background color got %v, want the picture dimmed", c)
+	}
+}
+
+func TestProcessImageFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "apod.jpg")
+	var buf bytes.Buffer
+	if err := jpeg.Encode(&buf, nasatest.Image(time.Now(), 640, 480), nil); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(path, buf.Bytes(), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := ProcessImageFile(path, path, ProcessFit(1920, 1080, FitSmartCrop), ProcessQuality(80)); err != nil {
+		t.Fatal(err)
+	}
+	a, err := AnalyzeImageFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if a.Width != 1920 || a.Height != 1080 {
+		t.Errorf("ProcessImageFile returned wrong size got %dx%d, want %dx%d", a.Width, a.Height, 1920, 1080)
+	}
+	if err := ProcessImage(bytes.NewReader([]byte("not an image")), &buf); err == nil {
+		t.Errorf("ProcessImage returned no error for an invalid picture")
+	}
+
+	// a small GIF claiming to be 65535x65535 isn't decoded
+	buf.Reset()
+	if err := gif.Encode(&buf, nasatest.Image(time.Now(), 4, 4), nil); err != nil {
+		t.Fatal(err)
+	}
+	huge := buf.Bytes()
+	copy(huge[6:10], []byte{0xff, 0xff, 0xff, 0xff}) // logical screen width and height
+	if err := ProcessImage(bytes.NewReader(huge), io.Discard); !errors.Is(err, ErrImageTooLarge) {
+		t.Errorf("ProcessImage returned wrong error for a huge picture got %v, want %v", err, ErrImageTooLarge)
+	}
+}
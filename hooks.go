@@ -0,0 +1,119 @@
+package nasa
+
+import (
+	"context"
+	"log/slog"
+	"time"
+)
+
+// Endpoint names reported to Hooks
+const (
+	EndpointAPOD = "apod"
+	EndpointNeo  = "neo"
+)
+
+// RequestInfo describes a request to the NASA API
+type RequestInfo struct {
+	Endpoint string // endpoint name e.g. EndpointAPOD
+	URL      string // request url, without the api key
+	Attempt  int    // 1 for the first attempt, incremented on retries
+}
+
+// ResponseInfo describes the outcome of a request to the NASA API
+type ResponseInfo struct {
+	RequestInfo
+	StatusCode int           // 0 if no response was received
+	Duration   time.Duration // time taken by the request
+	Err        error         // error of the request, if any
+}
+
+// RetryInfo describes a request that's about to be retried
+type RetryInfo struct {
+	RequestInfo
+	Err   error         // error of the failed attempt
+	Delay time.Duration // delay before the next attempt
+}
+
+// CacheInfo describes a cache lookup
+type CacheInfo struct {
+	Endpoint string
+	Key      string // cache key, the request url without the api key
+	Hit      bool
+}
+
+// Hooks observe what a client does, e.g. for metrics, logging and tracing.
+// Any of the funcs may be nil. Hooks are called synchronously, so they should be fast.
+type Hooks struct {
+	BeforeRequest func(ctx context.Context, info RequestInfo)
+	AfterResponse func(ctx context.Context, info ResponseInfo)
+	OnRetry       func(ctx context.Context, info RetryInfo)
+	OnCache       func(ctx context.Context, info CacheInfo)
+}
+
+// WithHooks adds hooks to the client, it can be used more than once
+func WithHooks(h Hooks) Option {
+	return func(c *Client) { c.hooks = append(c.hooks, h) }
+}
+
+func (c *Client) beforeRequest(ctx context.Context, info RequestInfo) {
+	for _, h := range c.hooks {
+		if h.BeforeRequest != nil {
+			h.BeforeRequest(ctx, info)
+		}
+	}
+}
+
+func (c *Client) afterResponse(ctx context.Context, info ResponseInfo) {
+	for _, h := range c.hooks {
+		if h.AfterResponse != nil {
+			h.AfterResponse(ctx, info)
+		}
+	}
+}
+
+func (c *Client) onRetry(ctx context.Context, info RetryInfo) {
+	for _, h := range c.hooks {
+		if h.OnRetry != nil {
+			h.OnRetry(ctx, info)
+		}
+	}
+}
+
+func (c *Client) onCache(ctx context.Context, info CacheInfo) {
+	for _, h := range c.hooks {
+		if h.OnCache != nil {
+			h.OnCache(ctx, info)
+		}
+	}
+}
+
+// SlogHooks returns Hooks that log to l: responses at Info level
+// (Warn if they failed), retries at Warn and requests and cache lookups at Debug.
+func SlogHooks(l *slog.Logger) Hooks {
+	return Hooks{
+		BeforeRequest: func(ctx context.Context, info RequestInfo) {
+			l.DebugContext(ctx, "nasa: request",
+				"endpoint", info.Endpoint, "url", info.URL, "attempt", info.Attempt)
+		},
+		AfterResponse: func(ctx context.Context, info ResponseInfo) {
+			attrs := []any{
+				"endpoint", info.Endpoint, "url", info.URL, "attempt", info.Attempt,
+				"status", info.StatusCode, "duration", info.Duration,
+			}
+			if info.Err != nil {
+				l.WarnContext(ctx, "nasa: request failed", append(attrs, "err", info.Err)...)
+				return
+			}
+			l.InfoContext(ctx, "nasa: response", attrs...)
+		},
+		OnRetry: func(ctx context.Context, info RetryInfo) {
+			l.WarnContext(ctx, "nasa: retrying request",
+				"endpoint", info.Endpoint, "url", info.URL, "attempt", info.Attempt,
+				"delay", info.Delay, "err", info.Err)
+		},
+		OnCache: func(ctx context.Context, info CacheInfo) {
+			l.DebugContext(ctx, "nasa: cache lookup",
+				"endpoint", info.Endpoint, "key", info.Key, "hit", info.Hit)
+		},
+	}
+}
@@ -0,0 +1,94 @@
+package nasa
+
+import (
+	"bytes"
+	"context"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/peteretelej/nasa/nasatest"
+)
+
+func TestHooks(t *testing.T) {
+	var requests, responses, failures, retries, hits, misses int
+	hooks := Hooks{
+		BeforeRequest: func(ctx context.Context, info RequestInfo) {
+			requests++
+			if strings.Contains(info.URL, "api_key") {
+				t.Errorf("hooks received url with the api key: %s", info.URL)
+			}
+		},
+		AfterResponse: func(ctx context.Context, info ResponseInfo) {
+			responses++
+			if info.Err != nil {
+				failures++
+			}
+			if info.Endpoint != EndpointAPOD {
+				t.Errorf("AfterResponse got wrong endpoint got %q, want %q", info.Endpoint, EndpointAPOD)
+			}
+		},
+		OnRetry: func(ctx context.Context, info RetryInfo) { retries++ },
+		OnCache: func(ctx context.Context, info CacheInfo) {
+			if info.Hit {
+				hits++
+			} else {
+				misses++
+			}
+		},
+	}
+	c := NewClient(
+		WithHooks(hooks),
+		WithCache(NewMemoryCache(10)),
+		WithRetryPolicy(RetryPolicy{MaxAttempts: 2, BaseDelay: time.Millisecond}),
+	)
+	day := time.Date(2016, 1, 17, 0, 0, 0, 0, time.UTC)
+	fakeAPI.Fail(nasatest.ServerError, 1)
+	for i := 0; i < 2; i++ {
+		if _, err := c.APOD(day); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if requests != 2 || responses != 2 || failures != 1 || retries != 1 {
+		t.Errorf("hooks called wrong number of times got requests=%d responses=%d failures=%d retries=%d, want 2 2 1 1",
+			requests, responses, failures, retries)
+	}
+	if hits != 1 || misses != 1 {
+		t.Errorf("OnCache called wrong number of times got hits=%d misses=%d, want 1 1", hits, misses)
+	}
+}
+
+func TestSlogHooks(t *testing.T) {
+	var buf bytes.Buffer
+	l := slog.New(slog.NewTextHandler(&buf, nil))
+	c := NewClient(WithHooks(SlogHooks(l)))
+	if _, err := c.APOD(time.Date(2016, 1, 17, 0, 0, 0, 0, time.UTC)); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(buf.String(), "endpoint=apod") {
+		t.Errorf("SlogHooks did not log the response: %s", buf.String())
+	}
+}
+
+func TestSlogHooksScrubKey(t *testing.T) {
+	// nothing listens on the closed server's address, so dialing fails
+	ts := httptest.NewServer(http.NotFoundHandler())
+	ts.Close()
+
+	var buf bytes.Buffer
+	l := slog.New(slog.NewTextHandler(&buf, nil))
+	c := NewClient(WithBaseURL(ts.URL), WithAPIKey("SECRET"), WithRetryPolicy(NoRetry), WithHooks(SlogHooks(l)))
+	_, err := c.APOD(time.Date(2016, 1, 17, 0, 0, 0, 0, time.UTC))
+	if err == nil {
+		t.Fatal("APOD returned no error for a failed dial")
+	}
+	if strings.Contains(err.Error(), "SECRET") {
+		t.Errorf("APOD returned an error with the api key: %v", err)
+	}
+	if !strings.Contains(buf.String(), "request failed") || strings.Contains(buf.String(), "SECRET") {
+		t.Errorf("SlogHooks logged the api key or not the failure: %s", buf.String())
+	}
+}
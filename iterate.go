@@ -0,0 +1,274 @@
+package nasa
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// DefaultIterWorkers is the number of APODs an APODIterator fetches at once by default
+const DefaultIterWorkers = 4
+
+// IterOption configures an APODIterator
+type IterOption func(*iterOptions)
+
+type iterOptions struct {
+	workers     int
+	waitOnLimit bool
+}
+
+// IterWorkers sets the number of APODs fetched at once
+func IterWorkers(n int) IterOption {
+	return func(o *iterOptions) { o.workers = n }
+}
+
+// IterWaitForQuota makes the iterator pause until the API quota resets when it's rate
+// limited, instead of stopping the walk
+func IterWaitForQuota() IterOption {
+	return func(o *iterOptions) { o.waitOnLimit = true }
+}
+
+// APODResult is the APOD of a date, or the error fetching it
+type APODResult struct {
+	Date Date
+	APOD *Image
+	Err  error
+}
+
+// APODIterator walks the APODs of a date range in order, fetching them concurrently.
+// Use it like a bufio.Scanner:
+//
+//	it := nasa.IterateAPODs(ctx, from, to)
+//	defer it.Close()
+//	for r, ok := it.Next(); ok; r, ok = it.Next() {
+//		if r.Err != nil {
+//			// no APOD for r.Date, the walk goes on
+//			continue
+//		}
+//		fmt.Println(r.APOD.Title)
+//	}
+//	if err := it.Err(); err != nil {
+//		// the walk stopped early
+//	}
+type APODIterator struct {
+	c      *Client
+	o      iterOptions
+	ctx    context.Context
+	cancel context.CancelFunc
+
+	pending chan chan APODResult // results in date order, buffered to bound fetches ahead
+
+	mu          sync.Mutex // protects the following
+	err         error
+	pausedUntil time.Time
+	closed      bool
+}
+
+// IterateAPODs returns an iterator over the APODs from from to to, inclusive, fetched with
+// DefaultClient. See Client.IterateAPODs.
+func IterateAPODs(ctx context.Context, from, to Date, opts ...IterOption) *APODIterator {
+	return DefaultClient.IterateAPODs(ctx, from, to, opts...)
+}
+
+// IterateAPODs returns an iterator over the APODs from from to to, inclusive, walking
+// backward if to is before from. A zero from is the first APOD and a zero to the latest,
+// dates outside the archive are left out. APODs are fetched by a pool of workers, ahead
+// of the one being read. Close the iterator when done.
+//
+// Dates that fail, e.g. with no APOD, are reported as APODResult errors and the walk
+// goes on. When the API rate limits the walk it stops, unless IterWaitForQuota is set.
+// Invalid dates, e.g. January 40, stop the walk before it starts.
+func (c *Client) IterateAPODs(ctx context.Context, from, to Date, opts ...IterOption) *APODIterator {
+	dates, err := iterDates(from, to)
+	it := c.iterateDates(ctx, dates, opts...)
+	if err != nil {
+		it.stopped(err)
+	}
+	return it
+}
+
+// iterateDates returns an iterator over the APODs of dates, in their order
+func (c *Client) iterateDates(ctx context.Context, dates []Date, opts ...IterOption) *APODIterator {
+	o := iterOptions{workers: DefaultIterWorkers}
+	for _, opt := range opts {
+		opt(&o)
+	}
+	if o.workers < 1 {
+		o.workers = 1
+	}
+	ctx, cancel := context.WithCancel(ctx)
+	it := &APODIterator{
+		c:       c,
+		o:       o,
+		ctx:     ctx,
+		cancel:  cancel,
+		pending: make(chan chan APODResult, o.workers),
+	}
+
+	type job struct {
+		date Date
+		res  chan APODResult
+	}
+	jobs := make(chan job)
+	for i := 0; i < o.workers; i++ {
+		go func() {
+			for j := range jobs {
+				apod, err := it.fetch(j.date)
+				j.res <- APODResult{Date: j.date, APOD: apod, Err: err}
+			}
+		}()
+	}
+	go func() {
+		defer close(it.pending)
+		defer close(jobs)
+		for _, d := range dates {
+			res := make(chan APODResult, 1)
+			select {
+			case it.pending <- res:
+			case <-ctx.Done():
+				return
+			}
+			select {
+			case jobs <- job{d, res}:
+			case <-ctx.Done():
+				res <- APODResult{Date: d, Err: ctx.Err()}
+				return
+			}
+		}
+	}()
+	return it
+}
+
+// iterDates returns the dates from from to to, backward if to is before from,
+// leaving out dates outside the archive. A zero from is the first APOD, a zero to the latest.
+func iterDates(from, to Date) ([]Date, error) {
+	latest := LatestAPODDate()
+	if from.IsZero() {
+		from = FirstAPODDate
+	}
+	if to.IsZero() {
+		to = latest
+	}
+	for _, d := range []Date{from, to} {
+		if NewDate(d.Year, d.Month, d.Day) != d {
+			return nil, fmt.Errorf("invalid date %s", d)
+		}
+	}
+	// bound the walk by comparison, leaving out the dates outside the archive
+	var dates []Date
+	if !to.Before(from) {
+		if from.Before(FirstAPODDate) {
+			from = FirstAPODDate
+		}
+		for d := from; !d.After(to) && !d.After(latest); d = d.AddDays(1) {
+			dates = append(dates, d)
+		}
+	} else {
+		if from.After(latest) {
+			from = latest
+		}
+		for d := from; !d.Before(to) && !d.Before(FirstAPODDate); d = d.AddDays(-1) {
+			dates = append(dates, d)
+		}
+	}
+	return dates, nil
+}
+
+// fetch returns the APOD of d, pausing the walk while it's rate limited
+func (it *APODIterator) fetch(d Date) (*Image, error) {
+	for {
+		if err := it.wait(); err != nil {
+			return nil, err
+		}
+		apod, err := it.c.APODDateContext(it.ctx, d)
+		if !errors.Is(err, ErrRateLimited) || !it.o.waitOnLimit {
+			return apod, err
+		}
+		it.pause(err)
+	}
+}
+
+// wait blocks while the walk is paused
+func (it *APODIterator) wait() error {
+	it.mu.Lock()
+	until := it.pausedUntil
+	it.mu.Unlock()
+	if d := time.Until(until); d > 0 {
+		t := time.NewTimer(d)
+		defer t.Stop()
+		select {
+		case <-it.ctx.Done():
+			return it.ctx.Err()
+		case <-t.C:
+		}
+	}
+	return it.ctx.Err()
+}
+
+// rateLimitPause is how long the walk pauses when rate limited without
+// a Retry-After or a known quota window
+const rateLimitPause = time.Minute
+
+// pause pauses the walk until the rate limit of err is over
+func (it *APODIterator) pause(err error) {
+	until := time.Now().Add(rateLimitPause)
+	var apiErr *APIError
+	if errors.As(err, &apiErr) && apiErr.RetryAfter > 0 {
+		until = time.Now().Add(apiErr.RetryAfter)
+	} else if q := it.c.Quota(); q.Known() && q.Resets().After(time.Now()) {
+		until = q.Resets()
+	}
+	it.mu.Lock()
+	if until.After(it.pausedUntil) {
+		it.pausedUntil = until
+	}
+	it.mu.Unlock()
+}
+
+// Next returns the next APOD of the walk, ok is false once the walk is over.
+// Check r.Err for errors fetching the date and Err for errors that stopped the walk.
+func (it *APODIterator) Next() (r APODResult, ok bool) {
+	res, ok := <-it.pending
+	if !ok {
+		it.stopped(it.ctx.Err())
+		return APODResult{}, false
+	}
+	r = <-res
+	if it.ctx.Err() != nil && errors.Is(r.Err, it.ctx.Err()) {
+		// cancelled, the dates left were not fetched
+		it.stopped(it.ctx.Err())
+		return APODResult{}, false
+	}
+	if errors.Is(r.Err, ErrRateLimited) {
+		it.stopped(fmt.Errorf("walk stopped at %s: %w", r.Date, r.Err))
+		it.cancel()
+	}
+	return r, true
+}
+
+// stopped records the error that stopped the walk early, if it's the first one
+func (it *APODIterator) stopped(err error) {
+	it.mu.Lock()
+	if it.err == nil && !it.closed {
+		it.err = err
+	}
+	it.mu.Unlock()
+}
+
+// Err returns the error that stopped the walk early, e.g. a cancelled context
+// or a rate limit. It's nil if the walk went through all dates or was closed.
+func (it *APODIterator) Err() error {
+	it.mu.Lock()
+	defer it.mu.Unlock()
+	return it.err
+}
+
+// Close stops the walk, it's safe to call more than once
+func (it *APODIterator) Close() {
+	it.mu.Lock()
+	it.closed = true
+	it.mu.Unlock()
+	it.cancel()
+}
@@ -0,0 +1,138 @@
+package nasa
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/peteretelej/nasa/nasatest"
+)
+
+func TestIterateAPODs(t *testing.T) {
+	ts := nasatest.NewServer()
+	defer ts.Close()
+	ts.AddGap(time.Date(1995, 6, 18, 0, 0, 0, 0, time.UTC))
+	c := NewClient(WithBaseURL(ts.URL), WithRetryPolicy(NoRetry))
+	first, last := FirstAPODDate, FirstAPODDate.AddDays(9)
+	gap := Date{1995, time.June, 18}
+
+	tests := []struct {
+		from, to Date
+		workers  int
+	}{
+		{first, last, 3},
+		{last, first, 3},
+		{first.AddDays(-5), first.AddDays(1), 1}, // dates before the first APOD are left out
+		{first, first, 0},
+	}
+	for _, v := range tests {
+		want, _ := iterDates(v.from, v.to)
+		it := c.IterateAPODs(context.Background(), v.from, v.to, IterWorkers(v.workers))
+		var got []Date
+		for r, ok := it.Next(); ok; r, ok = it.Next() {
+			got = append(got, r.Date)
+			switch {
+			case r.Date == gap:
+				if r.Err == nil {
+					t.Errorf("IterateAPODs returned no error for the gap on %s", gap)
+				}
+			case r.Err != nil:
+				t.Errorf("IterateAPODs returned error for %s: %v", r.Date, r.Err)
+			case r.APOD == nil || r.APOD.Date != r.Date:
+				t.Errorf("IterateAPODs returned wrong APOD for %s got %+v", r.Date, r.APOD)
+			}
+		}
+		it.Close()
+		if err := it.Err(); err != nil {
+			t.Errorf("IterateAPODs(%s, %s) stopped with error: %v", v.from, v.to, err)
+		}
+		if !reflect.DeepEqual(got, want) {
+			t.Errorf("IterateAPODs(%s, %s) returned wrong dates got %v, want %v", v.from, v.to, got, want)
+		}
+	}
+}
+
+func TestIterDates(t *testing.T) {
+	latest := LatestAPODDate()
+	tests := []struct {
+		from, to    Date
+		n           int
+		first, last Date
+	}{
+		{FirstAPODDate, FirstAPODDate.AddDays(2), 3, FirstAPODDate, FirstAPODDate.AddDays(2)},
+		{FirstAPODDate.AddDays(2), FirstAPODDate.AddDays(-30), 3, FirstAPODDate.AddDays(2), FirstAPODDate},
+		{latest.AddDays(-2), Date{}, 3, latest.AddDays(-2), latest},                     // zero to is the latest APOD
+		{latest.AddDays(-2), latest.AddDays(400), 3, latest.AddDays(-2), latest},        // after the latest APOD
+		{latest.AddDays(300), latest.AddDays(-1), 2, latest, latest.AddDays(-1)},        // backward from the future
+		{Date{1990, time.January, 1}, Date{1990, time.December, 31}, 0, Date{}, Date{}}, // before the first APOD
+		{Date{1, time.January, 1}, FirstAPODDate, 1, FirstAPODDate, FirstAPODDate},      // far before the first APOD
+		{Date{}, FirstAPODDate.AddDays(1), 2, FirstAPODDate, FirstAPODDate.AddDays(1)},  // zero from is the first APOD
+	}
+	for _, v := range tests {
+		dates, err := iterDates(v.from, v.to)
+		if err != nil {
+			t.Errorf("iterDates(%s, %s) returned error: %v", v.from, v.to, err)
+			continue
+		}
+		if len(dates) != v.n {
+			t.Errorf("iterDates(%s, %s) returned wrong number of dates got %d, want %d", v.from, v.to, len(dates), v.n)
+			continue
+		}
+		if v.n > 0 && (dates[0] != v.first || dates[v.n-1] != v.last) {
+			t.Errorf("iterDates(%s, %s) returned wrong dates got %s to %s, want %s to %s", v.from, v.to, dates[0], dates[v.n-1], v.first, v.last)
+		}
+	}
+
+	// invalid dates stop the walk, rather than never reaching to
+	it := IterateAPODs(context.Background(), Date{2020, time.January, 1}, Date{2020, time.January, 40})
+	defer it.Close()
+	if _, ok := it.Next(); ok {
+		t.Errorf("IterateAPODs returned an APOD for an invalid date range")
+	}
+	if it.Err() == nil {
+		t.Errorf("IterateAPODs returned no error for an invalid date")
+	}
+}
+
+func TestIterateAPODsStops(t *testing.T) {
+	ts := nasatest.NewServer()
+	defer ts.Close()
+	ts.SetRateLimit(3)
+	c := NewClient(WithBaseURL(ts.URL), WithRetryPolicy(NoRetry))
+	from := FirstAPODDate
+
+	it := c.IterateAPODs(context.Background(), from, from.AddDays(99), IterWorkers(1))
+	n := 0
+	for r, ok := it.Next(); ok; r, ok = it.Next() {
+		if r.Err == nil {
+			n++
+		} else if !errors.Is(r.Err, ErrRateLimited) {
+			t.Errorf("IterateAPODs returned wrong error for %s: %v", r.Date, r.Err)
+		}
+	}
+	it.Close()
+	if n != 3 || !errors.Is(it.Err(), ErrRateLimited) {
+		t.Errorf("IterateAPODs returned %d APODs and error %v, want %d and the rate limit", n, it.Err(), 3)
+	}
+	if ts.Requests() > 6 {
+		t.Errorf("IterateAPODs made %d requests after it was rate limited", ts.Requests())
+	}
+
+	// the workers fetching ahead must not be rate limited before the cancel is seen
+	ts.SetRateLimit(1000)
+	ctx, cancel := context.WithCancel(context.Background())
+	it = NewClient(WithBaseURL(ts.URL), WithAPIKey("other")).IterateAPODs(ctx, from, from.AddDays(99))
+	defer it.Close()
+	if _, ok := it.Next(); !ok {
+		t.Fatalf("IterateAPODs stopped early: %v", it.Err())
+	}
+	cancel()
+	for _, ok := it.Next(); ok; _, ok = it.Next() {
+		// results fetched before the cancel
+	}
+	if !errors.Is(it.Err(), context.Canceled) {
+		t.Errorf("IterateAPODs returned wrong error got %v, want %v", it.Err(), context.Canceled)
+	}
+}
@@ -0,0 +1,150 @@
+package nasa
+
+import (
+	"math"
+	"strings"
+	"sync"
+	"time"
+)
+
+// WithAPIKeys sets a pool of API keys the client rotates between.
+// Requests use the key with the most quota left, keys that are rate limited
+// are skipped until their window resets and invalid keys are no longer used.
+// The NASAKEY environment variable may also hold a comma separated list of keys.
+func WithAPIKeys(keys ...string) Option {
+	return func(c *Client) {
+		c.apiKeys = nil
+		for _, k := range keys {
+			if k = strings.TrimSpace(k); k != "" {
+				c.apiKeys = append(c.apiKeys, k)
+			}
+		}
+	}
+}
+
+// KeyHealth reports the state of an API key in a client's pool
+type KeyHealth struct {
+	Key       string `json:"key"` // masked key
+	Quota     Quota  `json:"quota"`
+	Exhausted bool   `json:"exhausted"` // rate limited until Quota.Resets()
+	Invalid   bool   `json:"invalid"`   // rejected by the API as invalid
+}
+
+// keyPool tracks the rotation and invalid keys of a client
+type keyPool struct {
+	mu      sync.Mutex // protects the following
+	next    int
+	invalid map[string]bool
+}
+
+func (kp *keyPool) markInvalid(key string) {
+	kp.mu.Lock()
+	if kp.invalid == nil {
+		kp.invalid = make(map[string]bool)
+	}
+	kp.invalid[key] = true
+	kp.mu.Unlock()
+}
+
+func (kp *keyPool) isInvalid(key string) bool {
+	kp.mu.Lock()
+	defer kp.mu.Unlock()
+	return kp.invalid[key]
+}
+
+// rotate returns the offset to start looking for a key from
+func (kp *keyPool) rotate(n int) int {
+	kp.mu.Lock()
+	defer kp.mu.Unlock()
+	start := kp.next % n
+	kp.next++
+	return start
+}
+
+// keys returns the API keys of the client
+func (c *Client) keys() []string {
+	if len(c.apiKeys) > 0 {
+		return c.apiKeys
+	}
+	if c.apiKey != "" {
+		return []string{c.apiKey}
+	}
+	return envKeys()
+}
+
+// envKeys returns the keys in the NASAKEY environment variable
+func envKeys() []string {
+	var keys []string
+	for _, k := range strings.Split(nasaKey, ",") {
+		if k = strings.TrimSpace(k); k != "" {
+			keys = append(keys, k)
+		}
+	}
+	if len(keys) == 0 {
+		keys = []string{"DEMO_KEY"}
+	}
+	return keys
+}
+
+// exhausted reports whether key has no quota left in the current window
+func (c *Client) exhausted(key string) bool {
+	q := c.quotas.get(key)
+	return q.Known() && q.Remaining <= c.quotaReserve && time.Now().Before(q.Resets())
+}
+
+// pickKey returns the key to make the next request with: the valid key with the
+// most quota left, keys without a known quota first. Ties rotate between keys.
+func (c *Client) pickKey() string {
+	keys := c.keys()
+	if len(keys) == 1 {
+		return keys[0]
+	}
+	start := c.pool.rotate(len(keys))
+	best, bestRemaining := "", -1
+	for i := range keys {
+		k := keys[(start+i)%len(keys)]
+		if c.pool.isInvalid(k) || c.exhausted(k) {
+			continue
+		}
+		remaining := math.MaxInt32
+		if q := c.quotas.get(k); q.Known() {
+			remaining = q.Remaining
+		}
+		if remaining > bestRemaining {
+			best, bestRemaining = k, remaining
+		}
+	}
+	if best == "" {
+		// all keys are exhausted or invalid, let the quota policy or API decide
+		return keys[start]
+	}
+	return best
+}
+
+// KeyHealth returns the health of each API key used by the client
+func (c *Client) KeyHealth() []KeyHealth {
+	keys := c.keys()
+	health := make([]KeyHealth, 0, len(keys))
+	for _, k := range keys {
+		health = append(health, KeyHealth{
+			Key:       maskKey(k),
+			Quota:     c.quotas.get(k),
+			Exhausted: c.exhausted(k),
+			Invalid:   c.pool.isInvalid(k),
+		})
+	}
+	return health
+}
+
+// maskKey hides most of an API key, so it can be displayed
+func maskKey(key string) string {
+	if key == "DEMO_KEY" || len(key) <= 4 {
+		return key
+	}
+	return key[:4] + strings.Repeat("*", len(key)-4)
+}
+
+// GetKeyHealth returns the health of each API key used by DefaultClient
+func GetKeyHealth() []KeyHealth {
+	return DefaultClient.KeyHealth()
+}
@@ -0,0 +1,68 @@
+package nasa
+
+import (
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/peteretelej/nasa/nasatest"
+)
+
+func TestKeyPool(t *testing.T) {
+	ts := nasatest.NewServer()
+	defer ts.Close()
+	ts.SetRateLimit(2)
+
+	c := NewClient(WithBaseURL(ts.URL), WithAPIKeys("KEY1", "KEY2", "KEY3"), WithRetryPolicy(NoRetry))
+	day := time.Date(2016, 1, 17, 0, 0, 0, 0, time.UTC)
+	for i := 0; i < 6; i++ {
+		if _, err := c.APOD(day); err != nil {
+			t.Fatalf("APOD %d failed with a key pool: %v", i, err)
+		}
+	}
+	if q := c.Quota(); q.Limit != 6 || q.Remaining != 0 {
+		t.Errorf("Quota returned wrong total got %d/%d, want 0/6", q.Remaining, q.Limit)
+	}
+	for _, k := range c.KeyHealth() {
+		if !k.Exhausted {
+			t.Errorf("key %s not reported exhausted", k.Key)
+		}
+	}
+	if _, err := c.APOD(day); !errors.Is(err, ErrRateLimited) {
+		t.Errorf("APOD returned wrong error with all keys exhausted got %v, want %v", err, ErrRateLimited)
+	}
+}
+
+func TestKeyPoolSkipsRateLimitedKeys(t *testing.T) {
+	c := NewClient(WithAPIKeys("KEY1", "KEY2"), WithRetryPolicy(NoRetry))
+	day := time.Date(2016, 1, 17, 0, 0, 0, 0, time.UTC)
+
+	fakeAPI.Fail(nasatest.RateLimited, 1)
+	if _, err := c.APOD(day); err != nil {
+		t.Fatalf("APOD did not move on to the next key: %v", err)
+	}
+	var exhausted int
+	for _, k := range c.KeyHealth() {
+		if k.Exhausted {
+			exhausted++
+		}
+	}
+	if exhausted != 1 {
+		t.Errorf("KeyHealth reported wrong number of exhausted keys got %d, want %d", exhausted, 1)
+	}
+
+	fakeAPI.Fail(nasatest.InvalidKey, 1)
+	if _, err := c.APOD(day); err != nil {
+		t.Fatalf("APOD did not move on from an invalid key: %v", err)
+	}
+}
+
+func TestMaskKey(t *testing.T) {
+	if got := maskKey("abcdefgh"); got != "abcd****" {
+		t.Errorf("maskKey returned wrong value got %q, want %q", got, "abcd****")
+	}
+	if strings.Contains(maskKey("SECRETKEY"), "KEY") {
+		t.Errorf("maskKey did not mask the key")
+	}
+}
@@ -0,0 +1,19 @@
+package nasa
+
+import (
+	"os"
+	"testing"
+
+	"github.com/peteretelej/nasa/nasatest"
+)
+
+// fakeAPI is the fake NASA API the tests run against
+var fakeAPI *nasatest.Server
+
+func TestMain(m *testing.M) {
+	fakeAPI = nasatest.NewServer()
+	APODEndpoint, NeoEndpoint = fakeAPI.APODEndpoint(), fakeAPI.NeoEndpoint()
+	code := m.Run()
+	fakeAPI.Close()
+	os.Exit(code)
+}
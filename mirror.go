@@ -0,0 +1,504 @@
+package nasa
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// MirrorIndex is the name of a mirror's metadata index, one JSON MirrorEntry per line
+const MirrorIndex = "index.ndjson"
+
+// MirrorGaps is the name of a mirror's list of dates without an APOD, one per line
+const MirrorGaps = "gaps.txt"
+
+// gapGraceDays is the number of days before the latest APOD whose gaps aren't
+// recorded in the mirror, as APODs are sometimes published late
+const gapGraceDays = 7
+
+// DefaultSyncConcurrency is the number of pictures Sync downloads at once by default
+const DefaultSyncConcurrency = 4
+
+// Mirror is a local copy of APODs, e.g. for offline kiosks. Its directory holds
+//
+//	index.ndjson                 metadata of each APOD, ordered by date
+//	gaps.txt                     dates without an APOD, not requested again
+//	images/YYYY/YYYY-MM-DD.jpg   pictures, thumbnails for videos
+//
+// Sync adds missing APODs to it, resuming where an earlier Sync left off.
+type Mirror struct {
+	dir string
+	c   *Client
+
+	mu      sync.Mutex // protects entries and gaps
+	entries map[Date]*MirrorEntry
+	gaps    map[Date]bool
+}
+
+// MirrorEntry is an APOD in a Mirror
+type MirrorEntry struct {
+	Image
+	File   string `json:"file,omitempty"`   // picture, relative to the mirror directory
+	SHA256 string `json:"sha256,omitempty"` // checksum of the picture
+
+	Analysis *ImageAnalysis `json:"analysis,omitempty"` // of the picture, see Mirror.Analyze
+}
+
+// OpenMirror opens the mirror in dir, using DefaultClient to sync it
+func OpenMirror(dir string) (*Mirror, error) {
+	return DefaultClient.OpenMirror(dir)
+}
+
+// OpenMirror opens the mirror in dir, creating dir if necessary
+func (c *Client) OpenMirror(dir string) (*Mirror, error) {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, err
+	}
+	m := &Mirror{dir: dir, c: c, entries: make(map[Date]*MirrorEntry), gaps: make(map[Date]bool)}
+	if err := m.loadGaps(); err != nil {
+		return nil, err
+	}
+	f, err := os.Open(filepath.Join(dir, MirrorIndex))
+	if os.IsNotExist(err) {
+		return m, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = f.Close() }()
+	sc := bufio.NewScanner(f)
+	sc.Buffer(make([]byte, 64<<10), 4<<20)
+	for line := 1; sc.Scan(); line++ {
+		if len(strings.TrimSpace(sc.Text())) == 0 {
+			continue
+		}
+		var e MirrorEntry
+		if err := json.Unmarshal(sc.Bytes(), &e); err != nil || e.Date.IsZero() {
+			return nil, fmt.Errorf("invalid mirror index %s line %d: %v", f.Name(), line, err)
+		}
+		m.entries[e.Date] = &e
+	}
+	if err := sc.Err(); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+// loadGaps reads the mirror's list of dates without an APOD, if it has one
+func (m *Mirror) loadGaps() error {
+	dat, err := os.ReadFile(filepath.Join(m.dir, MirrorGaps))
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	for i, line := range strings.Split(string(dat), "\n") {
+		if line = strings.TrimSpace(line); line == "" {
+			continue
+		}
+		d, err := ParseDate(line)
+		if err != nil {
+			return fmt.Errorf("invalid mirror gaps %s line %d: %v", MirrorGaps, i+1, err)
+		}
+		m.gaps[d] = true
+	}
+	return nil
+}
+
+// Dir returns the directory of the mirror
+func (m *Mirror) Dir() string { return m.dir }
+
+// Len returns the number of APODs in the mirror
+func (m *Mirror) Len() int {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return len(m.entries)
+}
+
+// Entry returns the APOD of date d, ok is false if it's not in the mirror
+func (m *Mirror) Entry(d Date) (e MirrorEntry, ok bool) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if pe, ok := m.entries[d]; ok {
+		return *pe, true
+	}
+	return MirrorEntry{}, false
+}
+
+// Entries returns the APODs in the mirror, ordered by date
+func (m *Mirror) Entries() []MirrorEntry {
+	m.mu.Lock()
+	entries := make([]MirrorEntry, 0, len(m.entries))
+	for _, e := range m.entries {
+		entries = append(entries, *e)
+	}
+	m.mu.Unlock()
+	sort.Slice(entries, func(i, j int) bool { return entries[i].Date.Before(entries[j].Date) })
+	return entries
+}
+
+// PicturePath returns the absolute path of the entry's picture, empty if it has none
+func (m *Mirror) PicturePath(e MirrorEntry) string {
+	if e.File == "" {
+		return ""
+	}
+	return filepath.Join(m.dir, filepath.FromSlash(e.File))
+}
+
+// save writes the index and gaps
+func (m *Mirror) save() error {
+	err := m.writeFile(MirrorIndex, func(w *bufio.Writer) error {
+		enc := json.NewEncoder(w)
+		for _, e := range m.Entries() {
+			if err := enc.Encode(e); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	m.mu.Lock()
+	gaps := make([]Date, 0, len(m.gaps))
+	for d := range m.gaps {
+		gaps = append(gaps, d)
+	}
+	m.mu.Unlock()
+	if len(gaps) == 0 {
+		return nil
+	}
+	sort.Slice(gaps, func(i, j int) bool { return gaps[i].Before(gaps[j]) })
+	return m.writeFile(MirrorGaps, func(w *bufio.Writer) error {
+		for _, d := range gaps {
+			if _, err := fmt.Fprintln(w, d); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// writeFile writes the mirror's file name with write, atomically so an interrupted
+// sync leaves the previous one
+func (m *Mirror) writeFile(name string, write func(*bufio.Writer) error) error {
+	tmp, err := os.CreateTemp(m.dir, "."+name+"-*")
+	if err != nil {
+		return err
+	}
+	w := bufio.NewWriter(tmp)
+	err = write(w)
+	if err == nil {
+		err = w.Flush()
+	}
+	if cerr := tmp.Close(); err == nil {
+		err = cerr
+	}
+	if err == nil {
+		err = os.Rename(tmp.Name(), filepath.Join(m.dir, name))
+	}
+	if err != nil {
+		_ = os.Remove(tmp.Name())
+	}
+	return err
+}
+
+// SyncOption configures Mirror.Sync
+type SyncOption func(*syncOptions)
+
+type syncOptions struct {
+	concurrency  int
+	quotaReserve int
+	noPictures   bool
+	analyze      bool
+	download     []DownloadOption
+	progress     func(SyncProgress)
+}
+
+// SyncConcurrency sets the number of pictures downloaded at once
+func SyncConcurrency(n int) SyncOption {
+	return func(o *syncOptions) { o.concurrency = n }
+}
+
+// SyncQuotaReserve stops the sync before fetching more metadata once the client's API
+// quota is down to n requests, leaving them for other uses of the key. By default the
+// sync stops once the quota is used up, rather than waiting for the API to rate limit it.
+func SyncQuotaReserve(n int) SyncOption {
+	return func(o *syncOptions) { o.quotaReserve = n }
+}
+
+// SyncMetadataOnly only syncs the index, pictures are not downloaded
+func SyncMetadataOnly() SyncOption {
+	return func(o *syncOptions) { o.noPictures = true }
+}
+
+// SyncAnalyze analyzes pictures as they are downloaded, see Mirror.Analyze
+func SyncAnalyze() SyncOption {
+	return func(o *syncOptions) { o.analyze = true }
+}
+
+// SyncDownloadOptions sets the options pictures are downloaded with, e.g. DownloadSD
+func SyncDownloadOptions(opts ...DownloadOption) SyncOption {
+	return func(o *syncOptions) { o.download = opts }
+}
+
+// SyncProgressFunc sets a func called as the sync progresses, from a single goroutine
+func SyncProgressFunc(f func(SyncProgress)) SyncOption {
+	return func(o *syncOptions) { o.progress = f }
+}
+
+// SyncProgress describes a step of a sync: a batch of metadata fetched or a picture downloaded
+type SyncProgress struct {
+	From, To Date  // dates of the metadata fetched, or the picture's date
+	Picture  bool  // whether a picture was downloaded, rather than metadata
+	Done     int   // pictures done so far
+	Total    int   // pictures to download
+	Err      error // error of the step, if any
+}
+
+// SyncFailure is an APOD that could not be synced
+type SyncFailure struct {
+	Date Date
+	Err  error
+}
+
+// SyncReport describes the outcome of a sync
+type SyncReport struct {
+	From, To   Date
+	Fetched    int           // APODs added to the index
+	Downloaded int           // pictures downloaded
+	Gaps       []Date        // dates found without an APOD, skipped by later syncs
+	Pending    []Date        // recent dates without an APOD yet, which may be published late, retried by later syncs
+	Failures   []SyncFailure // pictures that failed to download, retried by the next sync
+}
+
+func (r SyncReport) String() string {
+	s := fmt.Sprintf("Synced APODs from %s to %s: %d new APODs, %d pictures downloaded", r.From, r.To, r.Fetched, r.Downloaded)
+	if len(r.Gaps) > 0 {
+		s += fmt.Sprintf("\nNo APOD on %d dates: %s", len(r.Gaps), joinDates(r.Gaps))
+	}
+	if len(r.Pending) > 0 {
+		s += fmt.Sprintf("\nNo APOD yet on %d dates: %s", len(r.Pending), joinDates(r.Pending))
+	}
+	for _, f := range r.Failures {
+		s += fmt.Sprintf("\nFailed %s: %v", f.Date, f.Err)
+	}
+	return s
+}
+
+func joinDates(dates []Date) string {
+	s := make([]string, len(dates))
+	for i, d := range dates {
+		s[i] = d.String()
+	}
+	return strings.Join(s, ", ")
+}
+
+// Sync adds the APODs from from to to, inclusive, missing from the mirror and downloads
+// their pictures. Metadata is fetched 100 days per request, skipping dates known to have
+// no APOD. Before each request the client's quota is checked, the sync stops with an
+// error matching ErrQuotaExhausted once it's down to the reserve (see SyncQuotaReserve).
+// Pictures are downloaded concurrently, resuming partial downloads. The index is saved
+// as the sync progresses, so a sync that's interrupted or runs out of quota continues
+// where it left off. The report is returned even if the sync stopped early with an error.
+func (m *Mirror) Sync(ctx context.Context, from, to Date, opts ...SyncOption) (*SyncReport, error) {
+	o := syncOptions{concurrency: DefaultSyncConcurrency}
+	for _, opt := range opts {
+		opt(&o)
+	}
+	if o.concurrency < 1 {
+		o.concurrency = 1
+	}
+	if latest := LatestAPODDate(); to.IsZero() || to.After(latest) {
+		to = latest
+	}
+	if from.IsZero() || from.Before(FirstAPODDate) {
+		from = FirstAPODDate
+	}
+	r := &SyncReport{From: from, To: to}
+	if to.Before(from) {
+		return r, fmt.Errorf("invalid sync range, %s is after %s", from, to)
+	}
+	if err := m.syncMetadata(ctx, r, o); err != nil {
+		return r, err
+	}
+	if o.noPictures {
+		return r, nil
+	}
+	return r, m.syncPictures(ctx, r, o)
+}
+
+// missing returns the runs of dates from from to to that are not in the mirror nor
+// known gaps, split into runs of at most apodRangeLimit days
+func (m *Mirror) missing(from, to Date) [][2]Date {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	var runs [][2]Date
+	for d := from; !d.After(to); d = d.AddDays(1) {
+		if _, ok := m.entries[d]; ok || m.gaps[d] {
+			continue
+		}
+		if n := len(runs); n > 0 && runs[n-1][1] == d.AddDays(-1) && runs[n-1][1].Sub(runs[n-1][0]) < apodRangeLimit-1 {
+			runs[n-1][1] = d
+			continue
+		}
+		runs = append(runs, [2]Date{d, d})
+	}
+	return runs
+}
+
+func (m *Mirror) syncMetadata(ctx context.Context, r *SyncReport, o syncOptions) error {
+	grace := LatestAPODDate().AddDays(-gapGraceDays)
+	for _, run := range m.missing(r.From, r.To) {
+		if q := m.c.Quota(); q.Known() && q.Remaining <= o.quotaReserve && time.Now().Before(q.Resets()) {
+			return fmt.Errorf("sync stopped at %s with %d API requests left: %w", run[0], q.Remaining, ErrQuotaExhausted)
+		}
+		apods, err := m.c.APODRangeContext(ctx, run[0].Time(apodLocation), run[1].Time(apodLocation))
+		if o.progress != nil {
+			o.progress(SyncProgress{From: run[0], To: run[1], Err: err})
+		}
+		if err != nil {
+			return fmt.Errorf("sync stopped at %s: %w", run[0], err)
+		}
+		got := make(map[Date]bool, len(apods))
+		m.mu.Lock()
+		for _, apod := range apods {
+			if apod.Date.Before(run[0]) || apod.Date.After(run[1]) {
+				continue
+			}
+			got[apod.Date] = true
+			if _, ok := m.entries[apod.Date]; !ok {
+				m.entries[apod.Date] = &MirrorEntry{Image: apod}
+				r.Fetched++
+			}
+		}
+		for d := run[0]; !d.After(run[1]); d = d.AddDays(1) {
+			switch {
+			case got[d]:
+			case d.Before(grace):
+				r.Gaps = append(r.Gaps, d)
+				m.gaps[d] = true
+			default:
+				r.Pending = append(r.Pending, d)
+			}
+		}
+		m.mu.Unlock()
+		if err := m.save(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// pictureFile returns the path, relative to the mirror, to download the picture of e to
+func pictureFile(e MirrorEntry, rawurl string) string {
+	ext := strings.ToLower(path.Ext(strings.SplitN(rawurl, "?", 2)[0]))
+	switch ext {
+	case ".jpg", ".jpeg", ".png", ".gif", ".webp":
+	default:
+		ext = ".jpg"
+	}
+	return fmt.Sprintf("images/%04d/%s%s", e.Date.Year, e.Date, ext)
+}
+
+func (m *Mirror) syncPictures(ctx context.Context, r *SyncReport, o syncOptions) error {
+	sd := newDownloadOptions(o.download).sd
+	var todo []MirrorEntry
+	for _, e := range m.Entries() {
+		if e.Date.Before(r.From) || e.Date.After(r.To) || e.PictureURL(!sd) == "" {
+			continue
+		}
+		if e.File != "" {
+			if _, err := os.Stat(m.PicturePath(e)); err == nil {
+				continue
+			}
+		}
+		todo = append(todo, e)
+	}
+	if len(todo) == 0 {
+		return nil
+	}
+
+	type result struct {
+		e   MirrorEntry
+		res *DownloadResult
+		err error
+	}
+	jobs := make(chan MirrorEntry)
+	results := make(chan result)
+	var wg sync.WaitGroup
+	for i := 0; i < o.concurrency && i < len(todo); i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for e := range jobs {
+				e.File = pictureFile(e, e.PictureURL(!sd))
+				res, err := m.c.DownloadImageFileContext(ctx, e.Image, m.PicturePath(e), o.download...)
+				e.Analysis = nil
+				if err == nil && o.analyze {
+					// pictures that can't be decoded, e.g. WebP, are left unanalyzed
+					e.Analysis, _ = AnalyzeImageFile(m.PicturePath(e))
+				}
+				results <- result{e, res, err}
+			}
+		}()
+	}
+	go func() {
+		defer close(jobs)
+		for _, e := range todo {
+			select {
+			case jobs <- e:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+	go func() {
+		wg.Wait()
+		close(results)
+	}()
+
+	var stopErr error
+	done := 0
+	for res := range results {
+		done++
+		if res.err == nil {
+			m.mu.Lock()
+			if pe, ok := m.entries[res.e.Date]; ok {
+				pe.File, pe.SHA256, pe.Analysis = res.e.File, res.res.SHA256, res.e.Analysis
+			}
+			m.mu.Unlock()
+			r.Downloaded++
+		} else if ctx.Err() == nil {
+			r.Failures = append(r.Failures, SyncFailure{res.e.Date, res.err})
+		}
+		if o.progress != nil {
+			o.progress(SyncProgress{From: res.e.Date, To: res.e.Date, Picture: true, Done: done, Total: len(todo), Err: res.err})
+		}
+		if done%50 == 0 {
+			if err := m.save(); err != nil && stopErr == nil {
+				stopErr = err
+			}
+		}
+	}
+	sort.Slice(r.Failures, func(i, j int) bool { return r.Failures[i].Date.Before(r.Failures[j].Date) })
+	if err := m.save(); err != nil {
+		return err
+	}
+	if stopErr != nil {
+		return stopErr
+	}
+	if err := ctx.Err(); err != nil {
+		return fmt.Errorf("sync stopped: %w", err)
+	}
+	return nil
+}
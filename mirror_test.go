@@ -0,0 +1,132 @@
+package nasa
+
+import (
+	"context"
+	"errors"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/peteretelej/nasa/nasatest"
+)
+
+func TestMirrorSync(t *testing.T) {
+	ts := nasatest.NewServer()
+	defer ts.Close()
+	ts.AddGap(time.Date(1995, 6, 18, 0, 0, 0, 0, time.UTC))
+	c := NewClient(WithBaseURL(ts.URL), WithRetryPolicy(NoRetry))
+	ctx := context.Background()
+	dir := t.TempDir()
+	from, to := FirstAPODDate, FirstAPODDate.AddDays(9)
+
+	m, err := c.OpenMirror(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	r, err := m.Sync(ctx, from, to, SyncConcurrency(3))
+	if err != nil {
+		t.Fatal(err)
+	}
+	gap := Date{1995, time.June, 18}
+	if r.Fetched != 9 || r.Downloaded != 9 || len(r.Gaps) != 1 || r.Gaps[0] != gap || len(r.Failures) != 0 {
+		t.Errorf("Sync returned wrong report got %+v, want 9 APODs and pictures with a gap on %s", r, gap)
+	}
+
+	// the index and gaps are reloaded and only missing pictures are synced again
+	m, err = c.OpenMirror(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if m.Len() != 9 {
+		t.Fatalf("OpenMirror loaded wrong number of APODs got %d, want %d", m.Len(), 9)
+	}
+	e, ok := m.Entry(from)
+	if !ok || e.Title == "" || e.SHA256 == "" {
+		t.Fatalf("Mirror returned wrong entry for %s got %+v", from, e)
+	}
+	if err := os.Remove(m.PicturePath(e)); err != nil {
+		t.Fatal(err)
+	}
+	before := ts.Requests()
+	r, err = m.Sync(ctx, from, to)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := ts.Requests() - before; got != 0 {
+		t.Errorf("Sync made wrong number of API requests got %d, want %d, the gap is known", got, 0)
+	}
+	if r.Fetched != 0 || r.Downloaded != 1 {
+		t.Errorf("Sync returned wrong report got %+v, want only the removed picture downloaded", r)
+	}
+	entries := m.Entries()
+	for i := 1; i < len(entries); i++ {
+		if !entries[i-1].Date.Before(entries[i].Date) {
+			t.Errorf("Mirror returned entries out of order: %s before %s", entries[i-1].Date, entries[i].Date)
+		}
+	}
+
+	// a sync that runs out of quota stops, keeping what was synced
+	ts.Fail(nasatest.RateLimited, -1)
+	defer ts.Fail(nasatest.None, 0)
+	if _, err := m.Sync(ctx, from, to.AddDays(5)); !errors.Is(err, ErrRateLimited) {
+		t.Errorf("Sync returned wrong error got %v, want %v", err, ErrRateLimited)
+	}
+	if m, err = c.OpenMirror(dir); err != nil || m.Len() != 9 {
+		t.Errorf("Sync lost the index after failing (err %v)", err)
+	}
+}
+
+func TestMirrorSyncPending(t *testing.T) {
+	// recent dates without an APOD may still get one, they're synced again
+	ts := nasatest.NewServer()
+	defer ts.Close()
+	latest := LatestAPODDate()
+	pending := latest.AddDays(-2)
+	ts.AddGap(pending.Time(time.UTC))
+	c := NewClient(WithBaseURL(ts.URL), WithRetryPolicy(NoRetry))
+	m, err := c.OpenMirror(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	r, err := m.Sync(context.Background(), latest.AddDays(-4), latest)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(r.Gaps) != 0 || len(r.Pending) != 1 || r.Pending[0] != pending {
+		t.Errorf("Sync returned wrong report got %+v, want %s pending and no gaps", r, pending)
+	}
+	before := ts.Requests()
+	if _, err := m.Sync(context.Background(), latest.AddDays(-4), latest); err != nil {
+		t.Fatal(err)
+	}
+	if got := ts.Requests() - before; got != 1 {
+		t.Errorf("Sync made wrong number of API requests got %d, want %d for the pending date", got, 1)
+	}
+}
+
+func TestMirrorSyncQuota(t *testing.T) {
+	ts := nasatest.NewServer()
+	defer ts.Close()
+	ts.SetRateLimit(5)
+	c := NewClient(WithBaseURL(ts.URL), WithRetryPolicy(NoRetry))
+	m, err := c.OpenMirror(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// each request fetches 100 days, the sync stops with 3 requests left
+	from := FirstAPODDate
+	r, err := m.Sync(context.Background(), from, from.AddDays(499), SyncMetadataOnly(), SyncQuotaReserve(3))
+	if !errors.Is(err, ErrQuotaExhausted) {
+		t.Errorf("Sync returned wrong error got %v, want %v", err, ErrQuotaExhausted)
+	}
+	if got := ts.Requests(); got != 2 {
+		t.Errorf("Sync made wrong number of API requests got %d, want %d", got, 2)
+	}
+	if r.Fetched != 200 || m.Len() != 200 {
+		t.Errorf("Sync fetched wrong number of APODs got %d (%d in the mirror), want %d", r.Fetched, m.Len(), 200)
+	}
+	if q := c.Quota(); q.Remaining != 3 {
+		t.Errorf("Sync left wrong API quota got %d, want %d", q.Remaining, 3)
+	}
+}
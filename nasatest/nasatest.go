@@ -0,0 +1,608 @@
+// Package nasatest provides an in-process fake of the NASA APOD and NeoWs APIs,
+// so code using the nasa package can be tested offline.
+//
+// Example Usage
+//
+//	ts := nasatest.NewServer()
+//	defer ts.Close()
+//
+//	c := nasa.NewClient(nasa.WithBaseURL(ts.URL))
+//	// or, for the package level functions:
+//	nasa.APODEndpoint, nasa.NeoEndpoint = ts.APODEndpoint(), ts.NeoEndpoint()
+//
+//	ts.Fail(nasatest.RateLimited, 1) // the next request gets a 429
+//
+// APOD entries come from bundled fixtures, other dates get a generated entry.
+// Image urls point back to the fake server, which serves generated JPEG images.
+//
+// To record real API interactions and replay them, see package cassette.
+package nasatest
+
+import (
+	"bytes"
+	"embed"
+	"encoding/json"
+	"fmt"
+	"image"
+	"image/color"
+	"image/jpeg"
+	"io"
+	"math/rand"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Paths served by the fake server, these match api.nasa.gov
+const (
+	APODPath  = "/planetary/apod"
+	NeoPath   = "/neo/rest/v1/feed"
+	ImagePath = "/image/" // generated images, /image/YYYY-MM-DD.jpg and /image/YYYY-MM-DD-hd.jpg
+	PagePath  = "/apod/"  // APOD pages added with AddPage, /apod/apYYMMDD.html like apod.nasa.gov
+)
+
+// FirstAPOD is the date of the first APOD, earlier dates are out of range
+var FirstAPOD = time.Date(1995, 6, 16, 0, 0, 0, 0, time.UTC)
+
+// maxCount is the maximum count of random APODs returned at once
+const maxCount = 100
+
+// neoFeedLimit is the maximum number of days NeoWs returns in one request
+const neoFeedLimit = 7
+
+// Failure is a failure the fake server can simulate
+type Failure int
+
+// Failures simulated by Server.Fail
+const (
+	None          Failure = iota
+	RateLimited           // 429 with an OVER_RATE_LIMIT error body
+	InvalidKey            // 403 with an API_KEY_INVALID error body
+	ServerError           // 500 with an APOD error body
+	MalformedJSON         // 200 with a truncated JSON body
+	EmptyURL              // 200 with an APOD missing url and hdurl
+)
+
+//go:embed fixtures/*.json
+var fixtures embed.FS
+
+// APOD is an APOD entry as returned by the fake server
+type APOD struct {
+	Date           string `json:"date"`
+	Title          string `json:"title"`
+	Explanation    string `json:"explanation"`
+	Copyright      string `json:"copyright,omitempty"`
+	MediaType      string `json:"media_type"`
+	URL            string `json:"url,omitempty"`
+	HDURL          string `json:"hdurl,omitempty"`
+	ThumbnailURL   string `json:"thumbnail_url,omitempty"` // videos only, if requested with thumbs=true
+	ServiceVersion string `json:"service_version"`
+}
+
+// Server is a fake NASA API server. Create one with NewServer and Close it when done.
+type Server struct {
+	*httptest.Server
+
+	apods map[string]APOD
+	neos  map[string]json.RawMessage
+	gaps  map[string]bool
+	pages map[string]string // by file name
+
+	mu        sync.Mutex // protects the following
+	today     time.Time
+	failure   Failure
+	failures  int // number of requests left to fail, negative to fail all
+	truncate  int // number of image requests left to cut short
+	rateLimit int
+	remaining map[string]int
+	requests  int
+	rand      *rand.Rand
+}
+
+// NewServer starts and returns a fake NASA API server
+func NewServer() *Server {
+	s := &Server{
+		apods:     make(map[string]APOD),
+		gaps:      make(map[string]bool),
+		pages:     make(map[string]string),
+		neos:      make(map[string]json.RawMessage),
+		rateLimit: 1000,
+		remaining: make(map[string]int),
+		rand:      rand.New(rand.NewSource(1)),
+	}
+	if err := s.loadFixtures(); err != nil {
+		panic("nasatest: invalid fixtures: " + err.Error())
+	}
+	mux := http.NewServeMux()
+	mux.HandleFunc(APODPath, s.handleAPOD)
+	mux.HandleFunc(NeoPath, s.handleNeo)
+	mux.HandleFunc(ImagePath, s.handleImage)
+	mux.HandleFunc(PagePath, s.handlePage)
+	s.Server = httptest.NewServer(mux)
+	return s
+}
+
+func (s *Server) loadFixtures() error {
+	dat, err := fixtures.ReadFile("fixtures/apod.json")
+	if err != nil {
+		return err
+	}
+	var apods []APOD
+	if err := json.Unmarshal(dat, &apods); err != nil {
+		return err
+	}
+	for _, a := range apods {
+		s.apods[a.Date] = a
+	}
+	dat, err = fixtures.ReadFile("fixtures/neo.json")
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(dat, &s.neos)
+}
+
+// APODEndpoint returns the fake server's APOD endpoint
+func (s *Server) APODEndpoint() string { return s.URL + APODPath }
+
+// NeoEndpoint returns the fake server's NeoWs feed endpoint
+func (s *Server) NeoEndpoint() string { return s.URL + NeoPath }
+
+// SetToday sets the latest date the fake server has published an APOD for.
+// Defaults to the current date in New York, where APODs are published.
+func (s *Server) SetToday(t time.Time) {
+	s.mu.Lock()
+	s.today = time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, time.UTC)
+	s.mu.Unlock()
+}
+
+// Fail makes the next n API requests fail with f, a negative n fails all requests.
+// Fail(None, 0) stops failing requests.
+func (s *Server) Fail(f Failure, n int) {
+	s.mu.Lock()
+	s.failure, s.failures = f, n
+	s.mu.Unlock()
+}
+
+// TruncateImages makes the next n image responses stop halfway through the body,
+// like a dropped connection. Ranged requests, e.g. to resume, are served in full.
+func (s *Server) TruncateImages(n int) {
+	s.mu.Lock()
+	s.truncate = n
+	s.mu.Unlock()
+}
+
+// Seed seeds the selection of random APODs returned for count requests
+func (s *Server) Seed(seed int64) {
+	s.mu.Lock()
+	s.rand = rand.New(rand.NewSource(seed))
+	s.mu.Unlock()
+}
+
+// SetRateLimit sets the hourly requests allowed per API key, reported in the
+// X-RateLimit headers. Keys that use up the limit get 429 responses. Defaults to 1000.
+func (s *Server) SetRateLimit(limit int) {
+	s.mu.Lock()
+	s.rateLimit = limit
+	s.remaining = make(map[string]int)
+	s.mu.Unlock()
+}
+
+// Requests returns the number of API requests the server has received
+func (s *Server) Requests() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.requests
+}
+
+// AddAPOD adds or replaces the APOD entry for a.Date
+func (s *Server) AddAPOD(a APOD) {
+	s.mu.Lock()
+	s.apods[a.Date] = a
+	s.mu.Unlock()
+}
+
+// AddGap removes the APOD of day, like the days without an APOD early in the archive.
+// Requests for it get a 404 and ranges leave it out.
+func (s *Server) AddGap(day time.Time) {
+	d := day.Format("2006-01-02")
+	s.mu.Lock()
+	delete(s.apods, d)
+	s.gaps[d] = true
+	s.mu.Unlock()
+}
+
+// AddPage adds the HTML page of the APOD of day, served at PagePath like on apod.nasa.gov
+func (s *Server) AddPage(day time.Time, html string) {
+	s.mu.Lock()
+	s.pages[pageName(day)] = html
+	s.mu.Unlock()
+}
+
+// pageName returns the file name of the page of the APOD of day e.g. ap170513.html
+func pageName(day time.Time) string { return day.Format("ap060102.html") }
+
+// isGap reports whether there's no APOD on day
+func (s *Server) isGap(day time.Time) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.gaps[day.Format("2006-01-02")]
+}
+
+// apodLocation is where APODs are published, as in the nasa package's LatestAPODDate
+var apodLocation = loadLocation("America/New_York", -5*60*60)
+
+func loadLocation(name string, offset int) *time.Location {
+	if loc, err := time.LoadLocation(name); err == nil {
+		return loc
+	}
+	return time.FixedZone(name, offset) // no tz database, ignore daylight saving
+}
+
+// latest returns the latest published APOD date
+func (s *Server) latest() time.Time {
+	if !s.today.IsZero() {
+		return s.today
+	}
+	now := time.Now().In(apodLocation)
+	return time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, time.UTC)
+}
+
+// begin records an API request and returns the failure to simulate, if any.
+// It writes the rate limit headers for the request's API key.
+func (s *Server) begin(w http.ResponseWriter, r *http.Request) Failure {
+	key := r.URL.Query().Get("api_key")
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.requests++
+	if key == "" {
+		return InvalidKey
+	}
+	remaining, ok := s.remaining[key]
+	if !ok {
+		remaining = s.rateLimit
+	}
+	w.Header().Set("X-RateLimit-Limit", strconv.Itoa(s.rateLimit))
+	if remaining <= 0 {
+		w.Header().Set("X-RateLimit-Remaining", "0")
+		return RateLimited
+	}
+	remaining--
+	s.remaining[key] = remaining
+	w.Header().Set("X-RateLimit-Remaining", strconv.Itoa(remaining))
+	if s.failures != 0 {
+		if s.failures > 0 {
+			s.failures--
+		}
+		return s.failure
+	}
+	return None
+}
+
+// writeFailure writes the response for f, returns false if f is not an error response
+func writeFailure(w http.ResponseWriter, f Failure) bool {
+	switch f {
+	case RateLimited:
+		writeJSON(w, http.StatusTooManyRequests, map[string]interface{}{
+			"error": map[string]string{
+				"code":    "OVER_RATE_LIMIT",
+				"message": "You have exceeded your rate limit. Try again later or contact us at https://api.nasa.gov:443/contact/ for assistance",
+			},
+		})
+	case InvalidKey:
+		writeJSON(w, http.StatusForbidden, map[string]interface{}{
+			"error": map[string]string{
+				"code":    "API_KEY_INVALID",
+				"message": "An invalid api_key was supplied. Get one at https://api.nasa.gov:443",
+			},
+		})
+	case ServerError:
+		writeJSON(w, http.StatusInternalServerError, map[string]interface{}{
+			"code": 500, "msg": "Internal Service Error", "service_version": "v1",
+		})
+	case MalformedJSON:
+		w.Header().Set("Content-Type", "application/json")
+		fmt.Fprint(w, `{"date":"2017-05-11","title":"Trunc`)
+	default:
+		return false
+	}
+	return true
+}
+
+func writeJSON(w http.ResponseWriter, status int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(v)
+}
+
+// apodError writes an APOD service 400 error
+func apodError(w http.ResponseWriter, msg string) {
+	writeJSON(w, http.StatusBadRequest, map[string]interface{}{
+		"code": 400, "msg": msg, "service_version": "v1",
+	})
+}
+
+func (s *Server) handleAPOD(w http.ResponseWriter, r *http.Request) {
+	f := s.begin(w, r)
+	if writeFailure(w, f) {
+		return
+	}
+	s.mu.Lock()
+	latest := s.latest()
+	s.mu.Unlock()
+
+	q := r.URL.Query()
+	thumbs := q.Get("thumbs") == "true"
+	if q.Get("count") != "" && (q.Get("date") != "" || q.Get("start_date") != "" || q.Get("end_date") != "") {
+		apodError(w, "Bad Request: invalid field combination passed. Allowed request fields for apod method are 'concept_tags', 'date', 'hd', 'count', 'start_date', 'end_date', 'thumbs'")
+		return
+	}
+	if q.Get("count") != "" {
+		s.apodCount(w, q.Get("count"), latest, thumbs, f)
+		return
+	}
+	if q.Get("start_date") != "" {
+		s.apodRange(w, q, latest, thumbs, f)
+		return
+	}
+	if q.Get("end_date") != "" {
+		apodError(w, "Bad Request: invalid field combination passed. Allowed request fields for apod method are 'concept_tags', 'date', 'hd', 'count', 'start_date', 'end_date', 'thumbs'")
+		return
+	}
+	day := latest
+	if d := q.Get("date"); d != "" {
+		t, ok := parseAPODDate(w, d)
+		if !ok {
+			return
+		}
+		day = t
+	}
+	if !inRange(w, latest, day) {
+		return
+	}
+	if s.isGap(day) {
+		writeJSON(w, http.StatusNotFound, map[string]interface{}{
+			"code": 404, "msg": "No data available for date: " + day.Format("2006-01-02"), "service_version": "v1",
+		})
+		return
+	}
+	a := s.apod(day, thumbs)
+	if f == EmptyURL {
+		a.URL, a.HDURL = "", ""
+	}
+	writeJSON(w, http.StatusOK, a)
+}
+
+// apodRange writes the APODs from start_date to end_date (default latest) as an array
+func (s *Server) apodRange(w http.ResponseWriter, q url.Values, latest time.Time, thumbs bool, f Failure) {
+	start, ok := parseAPODDate(w, q.Get("start_date"))
+	if !ok {
+		return
+	}
+	end := latest
+	if q.Get("end_date") != "" {
+		if end, ok = parseAPODDate(w, q.Get("end_date")); !ok {
+			return
+		}
+	}
+	if !inRange(w, latest, start) || !inRange(w, latest, end) {
+		return
+	}
+	if end.Before(start) {
+		apodError(w, "start_date cannot be after end_date")
+		return
+	}
+	apods := []APOD{}
+	for day := start; !day.After(end); day = day.AddDate(0, 0, 1) {
+		if s.isGap(day) {
+			continue
+		}
+		a := s.apod(day, thumbs)
+		if f == EmptyURL {
+			a.URL, a.HDURL = "", ""
+		}
+		apods = append(apods, a)
+	}
+	writeJSON(w, http.StatusOK, apods)
+}
+
+// apodCount writes count random APODs from the whole archive as an array
+func (s *Server) apodCount(w http.ResponseWriter, count string, latest time.Time, thumbs bool, f Failure) {
+	n, err := strconv.Atoi(count)
+	if err != nil || n < 1 || n > maxCount {
+		apodError(w, fmt.Sprintf("Count must be positive and cannot exceed %d", maxCount))
+		return
+	}
+	days := int(latest.Sub(FirstAPOD).Hours()/24) + 1
+	picks := make([]time.Time, n)
+	s.mu.Lock()
+	for i := range picks {
+		picks[i] = FirstAPOD.AddDate(0, 0, s.rand.Intn(days))
+	}
+	s.mu.Unlock()
+	apods := make([]APOD, 0, n)
+	for _, day := range picks {
+		a := s.apod(day, thumbs)
+		if f == EmptyURL {
+			a.URL, a.HDURL = "", ""
+		}
+		apods = append(apods, a)
+	}
+	writeJSON(w, http.StatusOK, apods)
+}
+
+func parseAPODDate(w http.ResponseWriter, d string) (time.Time, bool) {
+	t, err := time.Parse("2006-01-02", d)
+	if err != nil {
+		apodError(w, fmt.Sprintf("time data '%s' does not match format '%%Y-%%m-%%d'", d))
+		return t, false
+	}
+	return t, true
+}
+
+// inRange writes an error and returns false if day is outside the APOD archive
+func inRange(w http.ResponseWriter, latest, day time.Time) bool {
+	if day.Before(FirstAPOD) || day.After(latest) {
+		apodError(w, fmt.Sprintf("Date must be between %s and %s.",
+			FirstAPOD.Format("Jan 2, 2006"), latest.Format("Jan 2, 2006")))
+		return false
+	}
+	return true
+}
+
+// apod returns the APOD for day from the fixtures, or a generated one
+func (s *Server) apod(day time.Time, thumbs bool) APOD {
+	date := day.Format("2006-01-02")
+	s.mu.Lock()
+	a, ok := s.apods[date]
+	s.mu.Unlock()
+	if !ok {
+		a = APOD{
+			Date:           date,
+			Title:          "Fake APOD " + date,
+			Explanation:    "A generated Astronomy Picture of the Day for " + date + ", served by nasatest.",
+			MediaType:      "image",
+			ServiceVersion: "v1",
+		}
+	}
+	if a.MediaType == "image" && a.URL == "" {
+		a.URL = s.URL + ImagePath + date + ".jpg"
+		a.HDURL = s.URL + ImagePath + date + "-hd.jpg"
+	}
+	if a.MediaType == "video" && thumbs && a.ThumbnailURL == "" {
+		a.ThumbnailURL = s.URL + ImagePath + date + ".jpg"
+	}
+	if !thumbs {
+		a.ThumbnailURL = ""
+	}
+	return a
+}
+
+func (s *Server) handleNeo(w http.ResponseWriter, r *http.Request) {
+	if writeFailure(w, s.begin(w, r)) {
+		return
+	}
+	q := r.URL.Query()
+	start, err := time.Parse("2006-01-02", q.Get("start_date"))
+	if err != nil {
+		neoError(w, "Date Format Exception - Expected format (yyyy-mm-dd) - "+q.Get("start_date"))
+		return
+	}
+	end := start.AddDate(0, 0, neoFeedLimit)
+	if q.Get("end_date") != "" {
+		if end, err = time.Parse("2006-01-02", q.Get("end_date")); err != nil {
+			neoError(w, "Date Format Exception - Expected format (yyyy-mm-dd) - "+q.Get("end_date"))
+			return
+		}
+	}
+	if end.Before(start) || end.Sub(start) > neoFeedLimit*24*time.Hour {
+		neoError(w, "Date Format Exception - Expected format (yyyy-mm-dd) - The Feed date limit is only 7 Days")
+		return
+	}
+
+	objects := make(map[string]json.RawMessage)
+	var count int
+	for day := start; !day.After(end); day = day.AddDate(0, 0, 1) {
+		date := day.Format("2006-01-02")
+		neos, ok := s.neos[date]
+		if !ok {
+			neos = json.RawMessage("[]")
+		}
+		var list []json.RawMessage
+		if err := json.Unmarshal(neos, &list); err == nil {
+			count += len(list)
+		}
+		objects[date] = neos
+	}
+	// like NeoWs, the links include the request's api key
+	link := func(start, end time.Time) string {
+		return fmt.Sprintf("%s%s?start_date=%s&end_date=%s&detailed=false&api_key=%s", s.URL, NeoPath,
+			start.Format("2006-01-02"), end.Format("2006-01-02"), url.QueryEscape(q.Get("api_key")))
+	}
+	days := end.Sub(start) + 24*time.Hour
+	links := map[string]string{
+		"self": link(start, end),
+		"next": link(start.Add(days), end.Add(days)),
+		"prev": link(start.Add(-days), end.Add(-days)),
+	}
+	writeJSON(w, http.StatusOK, map[string]interface{}{
+		"links":              links,
+		"element_count":      count,
+		"near_earth_objects": objects,
+	})
+}
+
+// neoError writes a NeoWs 400 error
+func neoError(w http.ResponseWriter, msg string) {
+	writeJSON(w, http.StatusBadRequest, map[string]interface{}{
+		"code": 400, "http_error": "BAD_REQUEST", "error_message": msg,
+	})
+}
+
+// handlePage serves the pages added with AddPage
+func (s *Server) handlePage(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	page, ok := s.pages[strings.TrimPrefix(r.URL.Path, PagePath)]
+	s.mu.Unlock()
+	if !ok {
+		http.NotFound(w, r)
+		return
+	}
+	w.Header().Set("Content-Type", "text/html")
+	_, _ = io.WriteString(w, page)
+}
+
+// handleImage serves a generated JPEG for /image/YYYY-MM-DD.jpg or /image/YYYY-MM-DD-hd.jpg
+func (s *Server) handleImage(w http.ResponseWriter, r *http.Request) {
+	name := strings.TrimSuffix(strings.TrimPrefix(r.URL.Path, ImagePath), ".jpg")
+	hd := strings.HasSuffix(name, "-hd")
+	day, err := time.Parse("2006-01-02", strings.TrimSuffix(name, "-hd"))
+	if err != nil {
+		http.NotFound(w, r)
+		return
+	}
+	width, height := 320, 240
+	if hd {
+		width, height = 1280, 960
+	}
+	var buf bytes.Buffer
+	_ = jpeg.Encode(&buf, Image(day, width, height), &jpeg.Options{Quality: 80})
+
+	s.mu.Lock()
+	truncate := s.truncate > 0 && r.Header.Get("Range") == ""
+	if truncate {
+		s.truncate--
+	}
+	s.mu.Unlock()
+	w.Header().Set("Content-Type", "image/jpeg")
+	w.Header().Set("Last-Modified", day.UTC().Format(http.TimeFormat))
+	if truncate {
+		w.Header().Set("Content-Length", strconv.Itoa(buf.Len()))
+		_, _ = w.Write(buf.Bytes()[:buf.Len()/2])
+		return // the server closes the connection as the body is short
+	}
+	// supports Range requests, for resuming downloads
+	http.ServeContent(w, r, name+".jpg", day, bytes.NewReader(buf.Bytes()))
+}
+
+// Image returns the generated image served for day: a gradient whose colors depend on the date
+func Image(day time.Time, width, height int) image.Image {
+	img := image.NewRGBA(image.Rect(0, 0, width, height))
+	seed := day.YearDay() + day.Year()
+	base := color.RGBA{uint8(seed * 37), uint8(seed * 59), uint8(seed * 83), 255}
+	for y := 0; y < height; y++ {
+		for x := 0; x < width; x++ {
+			img.SetRGBA(x, y, color.RGBA{
+				R: base.R + uint8(x*255/width),
+				G: base.G + uint8(y*255/height),
+				B: base.B,
+				A: 255,
+			})
+		}
+	}
+	return img
+}
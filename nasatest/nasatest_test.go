@@ -0,0 +1,194 @@
+package nasatest
+
+import (
+	"encoding/json"
+	"image/jpeg"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+)
+
+func get(t *testing.T, url string, v interface{}) *http.Response {
+	resp, err := http.Get(url)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = resp.Body.Close() }()
+	if v != nil {
+		_ = json.NewDecoder(resp.Body).Decode(v)
+	}
+	return resp
+}
+
+func TestAPOD(t *testing.T) {
+	ts := NewServer()
+	defer ts.Close()
+	ts.SetToday(time.Date(2017, 5, 13, 0, 0, 0, 0, time.UTC))
+
+	tests := []struct {
+		query  string
+		status int
+		title  string
+	}{
+		{"", http.StatusOK, "M104: The Sombrero Galaxy"},
+		{"&date=2013-11-08", http.StatusOK, "The Horsehead Nebula"},
+		{"&date=2016-01-17", http.StatusOK, "Fake APOD 2016-01-17"},
+		{"&date=1995-06-15", http.StatusBadRequest, ""},
+		{"&date=2017-05-14", http.StatusBadRequest, ""},
+		{"&date=17-05-14", http.StatusBadRequest, ""},
+	}
+	for _, v := range tests {
+		var a APOD
+		resp := get(t, ts.APODEndpoint()+"?api_key=TEST"+v.query, &a)
+		if resp.StatusCode != v.status {
+			t.Errorf("APOD%s returned wrong status got %d, want %d", v.query, resp.StatusCode, v.status)
+		}
+		if a.Title != v.title {
+			t.Errorf("APOD%s returned wrong title got %q, want %q", v.query, a.Title, v.title)
+		}
+	}
+
+	var a APOD
+	get(t, ts.APODEndpoint()+"?api_key=TEST&date=2016-01-17", &a)
+	resp := get(t, a.HDURL, nil)
+	if ct := resp.Header.Get("Content-Type"); ct != "image/jpeg" {
+		t.Errorf("image returned wrong content type got %q, want %q", ct, "image/jpeg")
+	}
+	resp, err := http.Get(a.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = resp.Body.Close() }()
+	if _, err := jpeg.Decode(resp.Body); err != nil {
+		t.Errorf("image is not a valid jpeg: %v", err)
+	}
+}
+
+func TestAPODRange(t *testing.T) {
+	ts := NewServer()
+	defer ts.Close()
+	ts.SetToday(time.Date(2017, 5, 13, 0, 0, 0, 0, time.UTC))
+
+	var apods []APOD
+	resp := get(t, ts.APODEndpoint()+"?api_key=TEST&start_date=2017-05-10&end_date=2017-05-12", &apods)
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("APOD range returned wrong status got %d, want %d", resp.StatusCode, http.StatusOK)
+	}
+	if len(apods) != 3 || apods[0].Date != "2017-05-10" || apods[2].Date != "2017-05-12" {
+		t.Errorf("APOD range returned wrong entries: %v", apods)
+	}
+	apods = nil
+	get(t, ts.APODEndpoint()+"?api_key=TEST&start_date=2017-05-12", &apods)
+	if len(apods) != 2 {
+		t.Errorf("APOD range without end_date returned wrong number of entries got %d, want %d", len(apods), 2)
+	}
+	resp = get(t, ts.APODEndpoint()+"?api_key=TEST&start_date=2017-05-12&end_date=2017-05-20", nil)
+	if resp.StatusCode != http.StatusBadRequest {
+		t.Errorf("APOD range into the future returned wrong status got %d, want %d", resp.StatusCode, http.StatusBadRequest)
+	}
+
+	ts.AddGap(time.Date(2017, 5, 11, 0, 0, 0, 0, time.UTC))
+	apods = nil
+	get(t, ts.APODEndpoint()+"?api_key=TEST&start_date=2017-05-10&end_date=2017-05-12", &apods)
+	if len(apods) != 2 || apods[1].Date != "2017-05-12" {
+		t.Errorf("APOD range returned the APOD of a gap: %v", apods)
+	}
+	resp = get(t, ts.APODEndpoint()+"?api_key=TEST&date=2017-05-11", nil)
+	if resp.StatusCode != http.StatusNotFound {
+		t.Errorf("APOD of a gap returned wrong status got %d, want %d", resp.StatusCode, http.StatusNotFound)
+	}
+}
+
+func TestAPODCount(t *testing.T) {
+	ts := NewServer()
+	defer ts.Close()
+
+	var apods []APOD
+	resp := get(t, ts.APODEndpoint()+"?api_key=TEST&count=5", &apods)
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("APOD count returned wrong status got %d, want %d", resp.StatusCode, http.StatusOK)
+	}
+	if len(apods) != 5 {
+		t.Errorf("APOD count returned wrong number of entries got %d, want %d", len(apods), 5)
+	}
+	for _, q := range []string{"&count=0", "&count=101", "&count=2&date=2017-05-11"} {
+		if resp := get(t, ts.APODEndpoint()+"?api_key=TEST"+q, nil); resp.StatusCode != http.StatusBadRequest {
+			t.Errorf("APOD%s returned wrong status got %d, want %d", q, resp.StatusCode, http.StatusBadRequest)
+		}
+	}
+}
+
+func TestNeo(t *testing.T) {
+	ts := NewServer()
+	defer ts.Close()
+
+	var nl struct {
+		ElementCount     int                          `json:"element_count"`
+		NearEarthObjects map[string][]json.RawMessage `json:"near_earth_objects"`
+	}
+	resp := get(t, ts.NeoEndpoint()+"?api_key=TEST&start_date=2017-05-11&end_date=2017-05-12", &nl)
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("Neo returned wrong status got %d, want %d", resp.StatusCode, http.StatusOK)
+	}
+	if nl.ElementCount != 3 || len(nl.NearEarthObjects) != 2 {
+		t.Errorf("Neo returned wrong objects got %d in %d days, want 3 in 2 days", nl.ElementCount, len(nl.NearEarthObjects))
+	}
+	resp = get(t, ts.NeoEndpoint()+"?api_key=TEST&start_date=2017-05-01&end_date=2017-05-12", nil)
+	if resp.StatusCode != http.StatusBadRequest {
+		t.Errorf("Neo returned wrong status for a long range got %d, want %d", resp.StatusCode, http.StatusBadRequest)
+	}
+}
+
+func TestFailures(t *testing.T) {
+	ts := NewServer()
+	defer ts.Close()
+
+	tests := []struct {
+		f      Failure
+		status int
+		body   string
+	}{
+		{RateLimited, http.StatusTooManyRequests, "OVER_RATE_LIMIT"},
+		{InvalidKey, http.StatusForbidden, "API_KEY_INVALID"},
+		{ServerError, http.StatusInternalServerError, "Internal Service Error"},
+		{MalformedJSON, http.StatusOK, `"Trunc`},
+		{EmptyURL, http.StatusOK, `"title"`},
+	}
+	for _, v := range tests {
+		ts.Fail(v.f, 1)
+		var body json.RawMessage
+		resp := get(t, ts.APODEndpoint()+"?api_key=TEST", &body)
+		if resp.StatusCode != v.status {
+			t.Errorf("Fail(%d) returned wrong status got %d, want %d", v.f, resp.StatusCode, v.status)
+		}
+		if v.f != MalformedJSON && !strings.Contains(string(body), v.body) {
+			t.Errorf("Fail(%d) returned body missing %s: %s", v.f, v.body, body)
+		}
+		if v.f == EmptyURL && strings.Contains(string(body), `"url"`) {
+			t.Errorf("Fail(EmptyURL) returned an APOD with a url")
+		}
+	}
+	if resp := get(t, ts.APODEndpoint()+"?api_key=TEST", nil); resp.StatusCode != http.StatusOK {
+		t.Errorf("Fail(f, 1) failed more than one request")
+	}
+}
+
+func TestRateLimit(t *testing.T) {
+	ts := NewServer()
+	defer ts.Close()
+	ts.SetRateLimit(2)
+
+	for i, want := range []int{http.StatusOK, http.StatusOK, http.StatusTooManyRequests} {
+		resp := get(t, ts.APODEndpoint()+"?api_key=TEST", nil)
+		if resp.StatusCode != want {
+			t.Errorf("request %d returned wrong status got %d, want %d", i, resp.StatusCode, want)
+		}
+	}
+	if resp := get(t, ts.APODEndpoint()+"?api_key=OTHER", nil); resp.StatusCode != http.StatusOK {
+		t.Errorf("rate limit not tracked per key, got %d", resp.StatusCode)
+	}
+	if ts.Requests() != 4 {
+		t.Errorf("Requests returned wrong count got %d, want %d", ts.Requests(), 4)
+	}
+}
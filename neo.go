@@ -1,16 +1,15 @@
 package nasa
 
 import (
-	"encoding/json"
+	"context"
 	"fmt"
-	"io/ioutil"
-	"net/http"
 	"net/url"
+	"sort"
 	"strings"
 	"time"
 )
 
-// NeoEndpoint defines the API Endpoint for NASA Neo Web service
+// NeoEndpoint defines the API Endpoint for NASA Neo Web service used by DefaultClient
 var NeoEndpoint = "https://api.nasa.gov/neo/rest/v1/feed"
 
 type diameter struct {
@@ -70,15 +69,26 @@ type NeoList struct {
 	Links struct {
 		Self, Next, Prev string
 	}
-	Start            string                `json:"-"`
-	End              string                `json:"-"`
-	ElementCount     int64                 `json:"element_count"`
-	NearEarthObjects map[string][]Asteroid `json:"near_earth_objects"`
+	Start            Date                `json:"-"`
+	End              Date                `json:"-"`
+	ElementCount     int64               `json:"element_count"`
+	NearEarthObjects map[Date][]Asteroid `json:"near_earth_objects"`
+}
+
+// Dates returns the dates with near earth objects, in order
+func (nl NeoList) Dates() []Date {
+	dates := make([]Date, 0, len(nl.NearEarthObjects))
+	for d := range nl.NearEarthObjects {
+		dates = append(dates, d)
+	}
+	sort.Slice(dates, func(i, j int) bool { return dates[i].Before(dates[j]) })
+	return dates
 }
 
 func (nl NeoList) String() string {
 	var neos string
-	for k, val := range nl.NearEarthObjects {
+	for _, k := range nl.Dates() {
+		val := nl.NearEarthObjects[k]
 		neos += fmt.Sprintf("%s: %d objects\n", k, len(val))
 		var objs []string
 		for _, each := range val {
@@ -96,35 +106,33 @@ Link: %s
 // NeoFeed returns a list of of asteroids based on their closest approach date to earth
 // Limits time to start and end times specified
 func NeoFeed(start, end time.Time) (*NeoList, error) {
-	u, err := url.Parse(NeoEndpoint)
-	if err != nil {
-		return nil, fmt.Errorf("unable to parse neo endpoint")
-	}
-	startdate, enddate := start.Format("2006-01-02"), end.Format("2006-01-02")
-	q := u.Query()
-	q.Set("api_key", nasaKey)
-	q.Add("start_date", startdate)
-	q.Add("end_date", enddate)
-	u.RawQuery = q.Encode()
-	req, err := http.NewRequest("GET", u.String(), nil)
-	if err != nil {
-		return nil, err
-	}
-	cl := &http.Client{Timeout: time.Second * 20}
-	resp, err := cl.Do(req)
-	if err != nil {
-		return nil, err
-	}
-	defer func() { _ = resp.Body.Close() }()
-	dat, err := ioutil.ReadAll(resp.Body)
-	if err != nil {
-		return nil, err
+	return DefaultClient.NeoFeedContext(context.Background(), start, end)
+}
+
+// NeoFeedContext is like NeoFeed but uses ctx for the API request
+func NeoFeedContext(ctx context.Context, start, end time.Time) (*NeoList, error) {
+	return DefaultClient.NeoFeedContext(ctx, start, end)
+}
+
+// NeoFeed returns a list of of asteroids based on their closest approach date to earth
+// Limits time to start and end times specified
+func (c *Client) NeoFeed(start, end time.Time) (*NeoList, error) {
+	return c.NeoFeedContext(context.Background(), start, end)
+}
+
+// NeoFeedContext is like NeoFeed but uses ctx for the API request
+func (c *Client) NeoFeedContext(ctx context.Context, start, end time.Time) (*NeoList, error) {
+	startdate, enddate := DateOf(start), DateOf(end)
+	q := url.Values{}
+	q.Add("start_date", startdate.String())
+	q.Add("end_date", enddate.String())
+	// asteroid data for the last week (and the future) may still change
+	var ttl time.Duration
+	if enddate.After(LatestAPODDate().AddDays(-7)) {
+		ttl = CacheTTLRecent
 	}
-	_ = resp.Body.Close()
 	var nl NeoList
-
-	err = json.Unmarshal(dat, &nl)
-	if err != nil {
+	if err := c.get(ctx, EndpointNeo, c.neoURL(), q, ttl, &nl); err != nil {
 		return nil, err
 	}
 	nl.Start = startdate
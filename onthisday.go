@@ -0,0 +1,79 @@
+package nasa
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+)
+
+// OnThisDay returns the APODs of month and day in every year of the archive, newest
+// first, using DefaultClient. See Client.OnThisDayContext.
+func OnThisDay(month time.Month, day int, opts ...IterOption) ([]Image, error) {
+	return DefaultClient.OnThisDayContext(context.Background(), month, day, opts...)
+}
+
+// OnThisDayContext is like OnThisDay but uses ctx for the requests
+func OnThisDayContext(ctx context.Context, month time.Month, day int, opts ...IterOption) ([]Image, error) {
+	return DefaultClient.OnThisDayContext(ctx, month, day, opts...)
+}
+
+// OnThisDay returns the APODs of month and day in every year of the archive, newest first
+func (c *Client) OnThisDay(month time.Month, day int, opts ...IterOption) ([]Image, error) {
+	return c.OnThisDayContext(context.Background(), month, day, opts...)
+}
+
+// OnThisDayContext is like OnThisDay but uses ctx for the requests. The APODs are
+// fetched concurrently, as by IterateAPODs, and past APODs come from the client's
+// cache if it has one. Years without an APOD on the day are left out. If some years
+// fail, the APODs of the others are returned with the error.
+func (c *Client) OnThisDayContext(ctx context.Context, month time.Month, day int, opts ...IterOption) ([]Image, error) {
+	dates, err := onThisDayDates(month, day)
+	if err != nil {
+		return nil, err
+	}
+	it := c.iterateDates(ctx, dates, opts...)
+	defer it.Close()
+	var apods []Image
+	var failed []error
+	for r, ok := it.Next(); ok; r, ok = it.Next() {
+		switch {
+		case r.Err == nil:
+			apods = append(apods, *r.APOD)
+		case !isNoAPOD(r.Err):
+			failed = append(failed, r.Err)
+		}
+	}
+	if err := it.Err(); err != nil {
+		return apods, err
+	}
+	if len(failed) > 0 {
+		return apods, fmt.Errorf("unable to get the APODs of %d years: %w", len(failed), failed[0])
+	}
+	return apods, nil
+}
+
+// onThisDayDates returns the dates of month and day in the archive, newest first
+func onThisDayDates(month time.Month, day int) ([]Date, error) {
+	// 2000 is a leap year, so February 29 is valid
+	if d := NewDate(2000, month, day); d.Month != month || d.Day != day {
+		return nil, fmt.Errorf("invalid day %s %d", month, day)
+	}
+	latest := LatestAPODDate()
+	var dates []Date
+	for year := latest.Year; year >= FirstAPODDate.Year; year-- {
+		d := NewDate(year, month, day)
+		if d.Month != month || d.Before(FirstAPODDate) || d.After(latest) {
+			continue // e.g. February 29 of a common year
+		}
+		dates = append(dates, d)
+	}
+	return dates, nil
+}
+
+// isNoAPOD reports whether err is the API's response for a date without an APOD
+func isNoAPOD(err error) bool {
+	var apiErr *APIError
+	return errors.As(err, &apiErr) && apiErr.StatusCode == http.StatusNotFound
+}
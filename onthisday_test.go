@@ -0,0 +1,77 @@
+package nasa
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/peteretelej/nasa/nasatest"
+)
+
+func TestOnThisDayDates(t *testing.T) {
+	latest := LatestAPODDate()
+	tests := []struct {
+		month time.Month
+		day   int
+		first Date // oldest date
+	}{
+		{time.June, 16, Date{1995, time.June, 16}},
+		{time.June, 15, Date{1996, time.June, 15}}, // before the first APOD in 1995
+		{time.February, 29, Date{1996, time.February, 29}},
+	}
+	for _, v := range tests {
+		dates, err := onThisDayDates(v.month, v.day)
+		if err != nil {
+			t.Fatalf("onThisDayDates(%s, %d) returned error: %v", v.month, v.day, err)
+		}
+		if got := dates[len(dates)-1]; got != v.first {
+			t.Errorf("onThisDayDates(%s, %d) returned wrong oldest date got %s, want %s", v.month, v.day, got, v.first)
+		}
+		for i, d := range dates {
+			if d.Month != v.month || d.Day != v.day || d.After(latest) {
+				t.Errorf("onThisDayDates(%s, %d) returned wrong date %s", v.month, v.day, d)
+			}
+			if i > 0 && !d.Before(dates[i-1]) {
+				t.Errorf("onThisDayDates(%s, %d) returned dates out of order: %s after %s", v.month, v.day, d, dates[i-1])
+			}
+		}
+	}
+	for _, v := range []struct {
+		month time.Month
+		day   int
+	}{{time.February, 30}, {time.April, 31}, {time.January, 0}, {13, 1}} {
+		if _, err := onThisDayDates(v.month, v.day); err == nil {
+			t.Errorf("onThisDayDates(%s, %d) returned no error for an invalid day", v.month, v.day)
+		}
+	}
+}
+
+func TestOnThisDay(t *testing.T) {
+	ts := nasatest.NewServer()
+	ts.AddGap(time.Date(1998, 6, 16, 0, 0, 0, 0, time.UTC))
+	c := NewClient(WithBaseURL(ts.URL), WithRetryPolicy(NoRetry), WithCache(NewMemoryCache(100)))
+
+	dates, _ := onThisDayDates(time.June, 16)
+	apods, err := c.OnThisDay(time.June, 16, IterWorkers(8))
+	if err != nil {
+		t.Fatalf("OnThisDay returned error: %v", err)
+	}
+	if len(apods) != len(dates)-1 {
+		t.Errorf("OnThisDay returned wrong number of APODs got %d, want %d", len(apods), len(dates)-1)
+	}
+	for i, apod := range apods {
+		if apod.Date.Year == 1998 {
+			t.Errorf("OnThisDay returned an APOD for the gap on %s", apod.Date)
+		}
+		if i > 0 && !apod.Date.Before(apods[i-1].Date) {
+			t.Errorf("OnThisDay returned APODs out of order: %s after %s", apod.Date, apods[i-1].Date)
+		}
+	}
+
+	// past APODs come from the cache, only the gap is requested again
+	ts.Close()
+	apods, err = c.OnThisDayContext(context.Background(), time.June, 16)
+	if err == nil || len(apods) != len(dates)-1 {
+		t.Errorf("OnThisDay without the API returned %d APODs and error %v, want %d and the gap's error", len(apods), err, len(dates)-1)
+	}
+}
@@ -0,0 +1,130 @@
+package nasa
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"fmt"
+	"image"
+	"image/draw"
+	"image/jpeg"
+	"image/png"
+	"io"
+	"os"
+	"path/filepath"
+)
+
+// DefaultJPEGQuality is the quality processed pictures are encoded with unless ProcessQuality is set
+const DefaultJPEGQuality = 90
+
+// MaxProcessPixels is the largest picture, in pixels, ProcessImage decodes
+const MaxProcessPixels = 40e6
+
+// ErrImageTooLarge is returned for pictures of more than MaxProcessPixels
+var ErrImageTooLarge = errors.New("nasa: picture too large to process")
+
+// ProcessOption is a step of processing a picture, or configures its encoding
+type ProcessOption func(*processOptions)
+
+type processOptions struct {
+	width, height int
+	mode          FitMode
+	png           bool
+	quality       int
+	caption       []string
+	captionOpts   []CaptionOption
+}
+
+// ProcessFit fits the picture to width x height with mode, see Fit
+func ProcessFit(width, height int, mode FitMode) ProcessOption {
+	return func(o *processOptions) { o.width, o.height, o.mode = width, height, mode }
+}
+
+// ProcessPNG encodes the processed picture as PNG instead of JPEG
+func ProcessPNG() ProcessOption {
+	return func(o *processOptions) { o.png = true }
+}
+
+// ProcessQuality sets the JPEG quality, from 1 to 100
+func ProcessQuality(q int) ProcessOption {
+	return func(o *processOptions) { o.quality = q }
+}
+
+func newProcessOptions(opts []ProcessOption) processOptions {
+	o := processOptions{mode: FitLetterbox, quality: DefaultJPEGQuality}
+	for _, opt := range opts {
+		opt(&o)
+	}
+	return o
+}
+
+// Process returns img processed by the steps of opts
+func Process(img image.Image, opts ...ProcessOption) image.Image {
+	return newProcessOptions(opts).process(img)
+}
+
+func (o processOptions) process(img image.Image) image.Image {
+	if o.width > 0 || o.height > 0 {
+		img = Fit(img, o.width, o.height, o.mode)
+	} else if o.caption != nil {
+		img = copyRGBA(img) // not to draw on the caller's image
+	}
+	if o.caption != nil {
+		DrawCaption(img.(draw.Image), o.caption, o.captionOpts...)
+	}
+	return img
+}
+
+// ProcessImage decodes a JPEG, PNG or GIF picture from r, processes it and writes
+// it to w as JPEG, or PNG with ProcessPNG. Pictures of more than MaxProcessPixels
+// fail with ErrImageTooLarge before they're decoded.
+func ProcessImage(r io.Reader, w io.Writer, opts ...ProcessOption) error {
+	o := newProcessOptions(opts)
+	var head bytes.Buffer // read by DecodeConfig, decoded again with the rest
+	cfg, _, err := image.DecodeConfig(io.TeeReader(r, &head))
+	if err != nil {
+		return fmt.Errorf("unable to decode image: %w", err)
+	}
+	if int64(cfg.Width)*int64(cfg.Height) > MaxProcessPixels {
+		return fmt.Errorf("%w: %dx%d", ErrImageTooLarge, cfg.Width, cfg.Height)
+	}
+	img, _, err := image.Decode(io.MultiReader(&head, r))
+	if err != nil {
+		return fmt.Errorf("unable to decode image: %w", err)
+	}
+	img = o.process(img)
+	if o.png {
+		return png.Encode(w, img)
+	}
+	return jpeg.Encode(w, img, &jpeg.Options{Quality: o.quality})
+}
+
+// ProcessImageFile processes the picture at src into dst, see ProcessImage.
+// dst is written atomically, so src and dst may be the same file.
+func ProcessImageFile(src, dst string, opts ...ProcessOption) error {
+	in, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = in.Close() }()
+
+	tmp, err := os.CreateTemp(filepath.Dir(dst), ".process-*")
+	if err != nil {
+		return err
+	}
+	w := bufio.NewWriter(tmp)
+	err = ProcessImage(bufio.NewReader(in), w, opts...)
+	if err == nil {
+		err = w.Flush()
+	}
+	if cerr := tmp.Close(); err == nil {
+		err = cerr
+	}
+	if err == nil {
+		err = os.Rename(tmp.Name(), dst)
+	}
+	if err != nil {
+		_ = os.Remove(tmp.Name())
+	}
+	return err
+}
@@ -0,0 +1,145 @@
+package nasa
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// QuotaWindow is the period over which api.nasa.gov rate limits apply
+const QuotaWindow = time.Hour
+
+// ErrQuotaExhausted is returned by clients using QuotaFailFast once the quota
+// left for the key reaches the reserve. It matches ErrRateLimited with errors.Is
+var ErrQuotaExhausted = fmt.Errorf("nasa: API quota exhausted: %w", ErrRateLimited)
+
+// Quota is the request quota of an API key as reported by the
+// X-RateLimit-Limit and X-RateLimit-Remaining response headers
+type Quota struct {
+	Limit     int       `json:"limit"`     // requests allowed per QuotaWindow
+	Remaining int       `json:"remaining"` // requests left in the current window
+	Updated   time.Time `json:"updated"`   // when the quota was last reported, zero if never
+}
+
+// Known reports whether the API has reported a quota for the key yet
+func (q Quota) Known() bool { return !q.Updated.IsZero() }
+
+// Resets estimates when the rate limit window resets.
+// api.nasa.gov uses a rolling window, so this is the latest it can take.
+func (q Quota) Resets() time.Time { return q.Updated.Add(QuotaWindow) }
+
+func (q Quota) String() string {
+	if !q.Known() {
+		return "Quota: unknown, no requests made yet"
+	}
+	return fmt.Sprintf(`Limit: %d requests/hour
+Remaining: %d
+Updated: %s
+`, q.Limit, q.Remaining, q.Updated.Format(time.RFC3339))
+}
+
+// QuotaPolicy defines what a client does when an API key's quota runs low
+type QuotaPolicy int
+
+// Quota policies
+const (
+	QuotaIgnore   QuotaPolicy = iota // only track the quota (default)
+	QuotaFailFast                    // fail with ErrQuotaExhausted without calling the API
+	QuotaWait                        // block until the quota window resets
+)
+
+// WithQuotaPolicy sets what the client does once the requests remaining for the key
+// drop to reserve. A reserve of 0 acts only when the quota is used up.
+func WithQuotaPolicy(p QuotaPolicy, reserve int) Option {
+	return func(c *Client) {
+		c.quotaPolicy = p
+		c.quotaReserve = reserve
+	}
+}
+
+// quotaTracker tracks the quotas reported for each API key
+type quotaTracker struct {
+	mu     sync.Mutex // protects quotas
+	quotas map[string]Quota
+}
+
+func (qt *quotaTracker) get(key string) Quota {
+	qt.mu.Lock()
+	q := qt.quotas[key]
+	qt.mu.Unlock()
+	return q
+}
+
+// update records the quota reported in the headers of a response
+func (qt *quotaTracker) update(key string, status int, h http.Header) {
+	limit, lerr := strconv.Atoi(h.Get("X-RateLimit-Limit"))
+	remaining, rerr := strconv.Atoi(h.Get("X-RateLimit-Remaining"))
+
+	qt.mu.Lock()
+	defer qt.mu.Unlock()
+	q := qt.quotas[key]
+	switch {
+	case status == http.StatusTooManyRequests:
+		if lerr == nil {
+			q.Limit = limit
+		}
+		q.Remaining = 0
+	case lerr == nil && rerr == nil:
+		q.Limit, q.Remaining = limit, remaining
+	default:
+		return
+	}
+	q.Updated = time.Now()
+	if qt.quotas == nil {
+		qt.quotas = make(map[string]Quota)
+	}
+	qt.quotas[key] = q
+}
+
+// Quota returns the last reported quota of the client's API key.
+// For clients with several keys it's the total of the keys' quotas.
+func (c *Client) Quota() Quota {
+	var total Quota
+	for _, k := range c.keys() {
+		q := c.quotas.get(k)
+		if !q.Known() {
+			continue
+		}
+		total.Limit += q.Limit
+		total.Remaining += q.Remaining
+		if q.Updated.After(total.Updated) {
+			total.Updated = q.Updated
+		}
+	}
+	return total
+}
+
+// GetQuota returns the last reported quota of DefaultClient's API key
+func GetQuota() Quota {
+	return DefaultClient.Quota()
+}
+
+// checkQuota applies the client's quota policy before a request is made with key
+func (c *Client) checkQuota(ctx context.Context, key string) error {
+	if c.quotaPolicy == QuotaIgnore {
+		return nil
+	}
+	q := c.quotas.get(key)
+	if !q.Known() || q.Remaining > c.quotaReserve || time.Now().After(q.Resets()) {
+		return nil
+	}
+	if c.quotaPolicy == QuotaFailFast {
+		return ErrQuotaExhausted
+	}
+	t := time.NewTimer(time.Until(q.Resets()))
+	defer t.Stop()
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-t.C:
+		return nil
+	}
+}
@@ -0,0 +1,66 @@
+package nasa
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+	"time"
+)
+
+func quotaServer(remaining *int) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("X-RateLimit-Limit", "30")
+		w.Header().Set("X-RateLimit-Remaining", strconv.Itoa(*remaining))
+		*remaining--
+		fmt.Fprint(w, `{"date":"2017-05-11","title":"Test","url":"https://apod.nasa.gov/a.jpg"}`)
+	}))
+}
+
+func TestQuota(t *testing.T) {
+	remaining := 2
+	ts := quotaServer(&remaining)
+	defer ts.Close()
+
+	c := NewClient(WithBaseURL(ts.URL), WithQuotaPolicy(QuotaFailFast, 0))
+	if c.Quota().Known() {
+		t.Errorf("Quota known before any request")
+	}
+	day := time.Date(2017, 5, 11, 0, 0, 0, 0, time.UTC)
+	for i := 0; i < 2; i++ {
+		if _, err := c.APOD(day); err != nil {
+			t.Fatal(err)
+		}
+	}
+	q := c.Quota()
+	if q.Limit != 30 || q.Remaining != 1 {
+		t.Errorf("Quota returned wrong quota got %d/%d, want 1/30", q.Remaining, q.Limit)
+	}
+	if _, err := c.APOD(day); err != nil {
+		t.Fatal(err)
+	}
+	_, err := c.APOD(day)
+	if !errors.Is(err, ErrQuotaExhausted) || !errors.Is(err, ErrRateLimited) {
+		t.Errorf("APOD returned wrong error for exhausted quota got %v, want %v", err, ErrQuotaExhausted)
+	}
+}
+
+func TestQuotaWait(t *testing.T) {
+	remaining := 0
+	ts := quotaServer(&remaining)
+	defer ts.Close()
+
+	c := NewClient(WithBaseURL(ts.URL), WithQuotaPolicy(QuotaWait, 0))
+	day := time.Date(2017, 5, 11, 0, 0, 0, 0, time.UTC)
+	if _, err := c.APOD(day); err != nil {
+		t.Fatal(err)
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	if _, err := c.APODContext(ctx, day); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("APODContext returned wrong error while waiting for quota got %v, want %v", err, context.DeadlineExceeded)
+	}
+}
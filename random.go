@@ -0,0 +1,380 @@
+package nasa
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/rand"
+	"net/url"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// defaultRandomDays is the window RandomAPOD picks from by default
+const defaultRandomDays = 2 * 365
+
+// maxRandomAttempts is how many APODs a RandomSelector fetches looking for
+// one of the wanted media types before giving up
+const maxRandomAttempts = 10
+
+// RandomOption configures how RandomAPOD and RandomSelector pick APODs
+type RandomOption func(*randomOptions)
+
+type randomOptions struct {
+	from, to Date // zero for the default window
+	media    map[string]bool
+	exclude  map[Date]bool
+	noRepeat int
+	seed     *int64
+}
+
+// RandomWindow picks APODs published from from to to, inclusive.
+// A zero to is the latest APOD.
+func RandomWindow(from, to Date) RandomOption {
+	return func(o *randomOptions) { o.from, o.to = from, to }
+}
+
+// RandomFullArchive picks APODs from the whole archive, since the first APOD in 1995
+func RandomFullArchive() RandomOption {
+	return RandomWindow(FirstAPODDate, Date{})
+}
+
+// RandomMediaTypes only picks APODs of the media types, e.g. MediaImage
+func RandomMediaTypes(types ...string) RandomOption {
+	return func(o *randomOptions) {
+		o.media = make(map[string]bool, len(types))
+		for _, t := range types {
+			o.media[t] = true
+		}
+	}
+}
+
+// RandomExclude never picks the dates, e.g. the APODs shown recently
+func RandomExclude(dates ...Date) RandomOption {
+	return func(o *randomOptions) {
+		if o.exclude == nil {
+			o.exclude = make(map[Date]bool, len(dates))
+		}
+		for _, d := range dates {
+			o.exclude[d] = true
+		}
+	}
+}
+
+// RandomNoRepeat makes a RandomSelector skip the last n dates it returned
+func RandomNoRepeat(n int) RandomOption {
+	return func(o *randomOptions) { o.noRepeat = n }
+}
+
+// RandomSeed seeds the random dates picked. Selectors with the same seed and options
+// return the same sequence of APODs, e.g. to show the same APODs on several displays.
+func RandomSeed(seed int64) RandomOption {
+	return func(o *randomOptions) { o.seed = &seed }
+}
+
+// RandomAPOD returns an Astronomy Picture of the Day based on a random date.
+// By default it picks any APOD of the last 2 years, opts change how it's picked.
+func RandomAPOD(opts ...RandomOption) (*Image, error) {
+	return DefaultClient.RandomAPODContext(context.Background(), opts...)
+}
+
+// RandomAPODContext is like RandomAPOD but uses ctx for the API requests
+func RandomAPODContext(ctx context.Context, opts ...RandomOption) (*Image, error) {
+	return DefaultClient.RandomAPODContext(ctx, opts...)
+}
+
+// RandomAPOD returns an Astronomy Picture of the Day based on a random date.
+// By default it picks any APOD of the last 2 years, opts change how it's picked.
+func (c *Client) RandomAPOD(opts ...RandomOption) (*Image, error) {
+	return c.RandomAPODContext(context.Background(), opts...)
+}
+
+// RandomAPODContext is like RandomAPOD but uses ctx for the API requests
+func (c *Client) RandomAPODContext(ctx context.Context, opts ...RandomOption) (*Image, error) {
+	return c.NewRandomSelector(opts...).Next(ctx)
+}
+
+// RandomSelector picks a sequence of APODs of random dates, one request per APOD
+// (cached like APOD). Unlike RandomPool its sequence can be seeded and filtered by date.
+type RandomSelector struct {
+	c    *Client
+	opts randomOptions
+
+	mu     sync.Mutex // protects the following
+	rng    *rand.Rand
+	recent []Date
+}
+
+// NewRandomSelector returns a RandomSelector drawing from DefaultClient
+func NewRandomSelector(opts ...RandomOption) *RandomSelector {
+	return DefaultClient.NewRandomSelector(opts...)
+}
+
+// NewRandomSelector returns a RandomSelector drawing from the client
+func (c *Client) NewRandomSelector(opts ...RandomOption) *RandomSelector {
+	s := &RandomSelector{c: c}
+	for _, opt := range opts {
+		opt(&s.opts)
+	}
+	seed := time.Now().UnixNano()
+	if s.opts.seed != nil {
+		seed = *s.opts.seed
+	}
+	s.rng = rand.New(rand.NewSource(seed))
+	return s
+}
+
+// window returns the dates to pick from
+func (s *RandomSelector) window() (from, to Date) {
+	latest := LatestAPODDate()
+	from, to = s.opts.from, s.opts.to
+	if to.IsZero() || to.After(latest) {
+		to = latest
+	}
+	if from.IsZero() {
+		from = to.AddDays(-defaultRandomDays + 1)
+	}
+	if from.Before(FirstAPODDate) {
+		from = FirstAPODDate
+	}
+	return from, to
+}
+
+// pick returns a random date in the window that's not excluded
+func (s *RandomSelector) pick(skip map[Date]bool) (Date, error) {
+	from, to := s.window()
+	days := to.Sub(from) + 1
+	if days < 1 {
+		return Date{}, fmt.Errorf("invalid random APOD window, %s is after %s", from, to)
+	}
+	excluded := 0
+	for d := range skip {
+		if !d.Before(from) && !d.After(to) {
+			excluded++
+		}
+	}
+	if excluded >= days {
+		return Date{}, fmt.Errorf("all APODs from %s to %s are excluded", from, to)
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for {
+		d := from.AddDays(s.rng.Intn(days))
+		if !skip[d] {
+			return d, nil
+		}
+	}
+}
+
+// Next returns the next random APOD
+func (s *RandomSelector) Next(ctx context.Context) (*Image, error) {
+	skip := make(map[Date]bool, len(s.opts.exclude))
+	for d := range s.opts.exclude {
+		skip[d] = true
+	}
+	s.mu.Lock()
+	for _, d := range s.recent {
+		skip[d] = true
+	}
+	s.mu.Unlock()
+
+	for attempt := 0; attempt < maxRandomAttempts; attempt++ {
+		d, err := s.pick(skip)
+		if err != nil {
+			return nil, err
+		}
+		apod, err := s.c.APODDateContext(ctx, d)
+		if err != nil {
+			return nil, err
+		}
+		if len(s.opts.media) > 0 && !s.opts.media[apod.MediaType] {
+			skip[d] = true
+			continue
+		}
+		s.remember(d)
+		return apod, nil
+	}
+	return nil, fmt.Errorf("no random APOD of the wanted media type found in %d attempts", maxRandomAttempts)
+}
+
+// remember adds d to the dates not repeated
+func (s *RandomSelector) remember(d Date) {
+	if s.opts.noRepeat < 1 {
+		return
+	}
+	s.mu.Lock()
+	s.recent = append(s.recent, d)
+	if len(s.recent) > s.opts.noRepeat {
+		s.recent = s.recent[len(s.recent)-s.opts.noRepeat:]
+	}
+	s.mu.Unlock()
+}
+
+// maxRandomCount is the most random APODs the API returns in one request
+const maxRandomCount = 100
+
+// RandomAPODs returns n random APODs from the whole APOD archive, fetched in one request
+// (or one per 100 APODs).
+func RandomAPODs(n int) ([]Image, error) {
+	return DefaultClient.RandomAPODsContext(context.Background(), n)
+}
+
+// RandomAPODsContext is like RandomAPODs but uses ctx for the API requests
+func RandomAPODsContext(ctx context.Context, n int) ([]Image, error) {
+	return DefaultClient.RandomAPODsContext(ctx, n)
+}
+
+// RandomAPODs returns n random APODs from the whole APOD archive, fetched in one request
+// (or one per 100 APODs).
+func (c *Client) RandomAPODs(n int) ([]Image, error) {
+	return c.RandomAPODsContext(context.Background(), n)
+}
+
+// RandomAPODsContext is like RandomAPODs but uses ctx for the API requests
+func (c *Client) RandomAPODsContext(ctx context.Context, n int) ([]Image, error) {
+	if n < 1 {
+		return nil, fmt.Errorf("invalid number of random APODs: %d", n)
+	}
+	apods := make([]Image, 0, n)
+	for len(apods) < n {
+		count := n - len(apods)
+		if count > maxRandomCount {
+			count = maxRandomCount
+		}
+		q := url.Values{}
+		q.Set("count", strconv.Itoa(count))
+		q.Set("thumbs", "true")
+		var batch []Image
+		if err := c.get(ctx, EndpointAPOD, c.apodURL(), q, -1, &batch); err != nil {
+			return nil, err
+		}
+		if len(batch) == 0 {
+			return nil, fmt.Errorf("NASA APOD API returned no random APODs: %w", ErrUpstreamDown)
+		}
+		for i := range batch {
+			batch[i].setApodDate()
+		}
+		apods = append(apods, batch...)
+	}
+	return apods, nil
+}
+
+// RandomPool is a buffer of random APODs fetched in batches with RandomAPODs.
+// When it runs low it's refilled in the background, so Next rarely waits for the API.
+// Requests of failed refills are reported to the client's hooks, and the error is
+// returned by Next once the pool runs out.
+type RandomPool struct {
+	// Filter, if set, drops APODs it returns false for, e.g. to only keep images.
+	// Set it before calling Next.
+	Filter func(Image) bool
+
+	c     *Client
+	batch int
+
+	mu        sync.Mutex // protects the following
+	apods     []Image
+	refilled  chan struct{} // closed when the background refill is done, nil if none
+	refillErr error         // of the last background refill, if it failed
+}
+
+// NewRandomPool returns a RandomPool drawing from DefaultClient, fetching batch APODs at a time
+func NewRandomPool(batch int) *RandomPool {
+	return DefaultClient.NewRandomPool(batch)
+}
+
+// NewRandomPool returns a RandomPool drawing from the client, fetching batch APODs at a time
+func (c *Client) NewRandomPool(batch int) *RandomPool {
+	if batch < 1 {
+		batch = 1
+	}
+	if batch > maxRandomCount {
+		batch = maxRandomCount
+	}
+	return &RandomPool{c: c, batch: batch}
+}
+
+// Next returns the next random APOD, fetching a batch first if the pool is empty.
+// If a background refill is under way it waits for it rather than fetching another batch.
+func (p *RandomPool) Next(ctx context.Context) (*Image, error) {
+	for fetches := 0; ; fetches++ {
+		p.mu.Lock()
+		if len(p.apods) > 0 {
+			apod := p.apods[0]
+			p.apods = p.apods[1:]
+			if len(p.apods) <= p.batch/4 && p.refilled == nil {
+				p.refilled = make(chan struct{})
+				go p.refill()
+			}
+			p.mu.Unlock()
+			return &apod, nil
+		}
+		if err := p.refillErr; err != nil {
+			p.refillErr = nil
+			p.mu.Unlock()
+			return nil, fmt.Errorf("unable to refill random APOD pool: %w", err)
+		}
+		refilled := p.refilled
+		p.mu.Unlock()
+
+		if fetches == 3 {
+			return nil, errors.New("no random APODs matched the pool's filter")
+		}
+		if refilled != nil {
+			select {
+			case <-refilled:
+				continue
+			case <-ctx.Done():
+				return nil, ctx.Err()
+			}
+		}
+		apods, err := p.fetch(ctx)
+		if err != nil {
+			return nil, err
+		}
+		p.add(apods)
+	}
+}
+
+// Len returns the number of APODs buffered in the pool
+func (p *RandomPool) Len() int {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return len(p.apods)
+}
+
+func (p *RandomPool) fetch(ctx context.Context) ([]Image, error) {
+	apods, err := p.c.RandomAPODsContext(ctx, p.batch)
+	if err != nil {
+		return nil, err
+	}
+	if p.Filter == nil {
+		return apods, nil
+	}
+	kept := apods[:0]
+	for _, apod := range apods {
+		if p.Filter(apod) {
+			kept = append(kept, apod)
+		}
+	}
+	return kept, nil
+}
+
+func (p *RandomPool) add(apods []Image) {
+	p.mu.Lock()
+	p.apods = append(p.apods, apods...)
+	p.mu.Unlock()
+}
+
+// refill fetches a batch in the background
+func (p *RandomPool) refill() {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+	defer cancel()
+	apods, err := p.fetch(ctx)
+	p.mu.Lock()
+	p.apods = append(p.apods, apods...)
+	p.refillErr = err
+	close(p.refilled)
+	p.refilled = nil
+	p.mu.Unlock()
+}
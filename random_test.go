@@ -0,0 +1,166 @@
+package nasa
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/peteretelej/nasa/nasatest"
+)
+
+func TestRandomAPODs(t *testing.T) {
+	before := fakeAPI.Requests()
+	apods, err := RandomAPODs(150)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(apods) != 150 {
+		t.Errorf("RandomAPODs returned wrong number of APODs got %d, want %d", len(apods), 150)
+	}
+	if got := fakeAPI.Requests() - before; got != 2 {
+		t.Errorf("RandomAPODs made wrong number of requests got %d, want %d", got, 2)
+	}
+	if apods[0].ApodDate.IsZero() {
+		t.Errorf("RandomAPODs did not set ApodDate")
+	}
+	if _, err := RandomAPODs(0); err == nil {
+		t.Errorf("RandomAPODs returned no error for 0 APODs")
+	}
+}
+
+func TestRandomPool(t *testing.T) {
+	pool := NewRandomPool(8)
+	pool.Filter = func(apod Image) bool { return apod.HDURL != "" }
+	ctx := context.Background()
+
+	before := fakeAPI.Requests()
+	seen := make(map[Date]bool)
+	for i := 0; i < 6; i++ {
+		apod, err := pool.Next(ctx)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if apod.HDURL == "" {
+			t.Errorf("RandomPool returned an APOD dropped by its filter")
+		}
+		seen[apod.Date] = true
+	}
+	// wait for the background refill
+	deadline := time.Now().Add(2 * time.Second)
+	for pool.Len() < 4 && time.Now().Before(deadline) {
+		time.Sleep(5 * time.Millisecond)
+	}
+	if pool.Len() < 4 {
+		t.Errorf("RandomPool was not refilled in the background, %d left", pool.Len())
+	}
+	if got := fakeAPI.Requests() - before; got != 2 {
+		t.Errorf("RandomPool made wrong number of requests got %d, want %d", got, 2)
+	}
+}
+
+func TestRandomPoolRefillError(t *testing.T) {
+	ts := nasatest.NewServer()
+	defer ts.Close()
+	pool := NewClient(WithBaseURL(ts.URL), WithRetryPolicy(NoRetry)).NewRandomPool(4)
+	ctx := context.Background()
+	for i := 0; i < 3; i++ {
+		if i == 2 {
+			ts.Fail(nasatest.ServerError, -1) // the background refill fails
+		}
+		if _, err := pool.Next(ctx); err != nil {
+			t.Fatal(err)
+		}
+	}
+	pool.mu.Lock()
+	refilled := pool.refilled
+	pool.mu.Unlock()
+	if refilled != nil {
+		<-refilled
+	}
+	ts.Fail(nasatest.None, 0)
+
+	if _, err := pool.Next(ctx); err != nil {
+		t.Errorf("RandomPool returned error with an APOD left: %v", err)
+	}
+	if _, err := pool.Next(ctx); !errors.Is(err, ErrUpstreamDown) {
+		t.Errorf("RandomPool returned wrong error once empty after a failed refill got %v, want %v", err, ErrUpstreamDown)
+	}
+	if _, err := pool.Next(ctx); err != nil {
+		t.Errorf("RandomPool returned error after the API recovered: %v", err)
+	}
+}
+
+func TestRandomPoolWaitsForRefill(t *testing.T) {
+	ts := nasatest.NewServer()
+	defer ts.Close()
+	pool := NewClient(WithBaseURL(ts.URL), WithRetryPolicy(NoRetry)).NewRandomPool(4)
+	ctx := context.Background()
+	// the third APOD starts a refill, Next waits for it once the pool is empty rather than fetching a batch
+	for i := 0; i < 8; i++ {
+		if _, err := pool.Next(ctx); err != nil {
+			t.Fatal(err)
+		}
+	}
+	pool.mu.Lock()
+	refilled := pool.refilled
+	pool.mu.Unlock()
+	if refilled != nil {
+		<-refilled
+	}
+	if got := ts.Requests(); got != 3 {
+		t.Errorf("RandomPool made wrong number of requests got %d, want %d", got, 3)
+	}
+}
+
+func TestRandomSelector(t *testing.T) {
+	ctx := context.Background()
+	from, to := NewDate(2017, time.May, 11), NewDate(2017, time.May, 13)
+
+	// 2017-05-12 is a video
+	apod, err := RandomAPOD(RandomWindow(from, to), RandomMediaTypes(MediaImage), RandomExclude(from))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if apod.Date != to {
+		t.Errorf("RandomAPOD returned wrong APOD got %s, want %s", apod.Date, to)
+	}
+
+	s := NewRandomSelector(RandomWindow(from, to), RandomNoRepeat(3))
+	seen := make(map[Date]bool)
+	for i := 0; i < 3; i++ {
+		apod, err := s.Next(ctx)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if seen[apod.Date] {
+			t.Errorf("RandomSelector repeated %s", apod.Date)
+		}
+		seen[apod.Date] = true
+	}
+	if _, err := s.Next(ctx); err == nil {
+		t.Errorf("RandomSelector returned no error when all dates were excluded")
+	}
+}
+
+func TestRandomSelectorSeed(t *testing.T) {
+	ctx := context.Background()
+	opts := []RandomOption{RandomFullArchive(), RandomSeed(42)}
+	a, b := NewRandomSelector(opts...), NewRandomSelector(opts...)
+	for i := 0; i < 5; i++ {
+		apodA, err := a.Next(ctx)
+		if err != nil {
+			t.Fatal(err)
+		}
+		apodB, err := b.Next(ctx)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if apodA.Date != apodB.Date {
+			t.Errorf("RandomSelectors with the same seed returned different APODs got %s, want %s", apodB.Date, apodA.Date)
+		}
+		if apodA.Date.Before(FirstAPODDate) {
+			t.Errorf("RandomSelector returned an APOD before the archive got %s", apodA.Date)
+		}
+	}
+}
@@ -0,0 +1,104 @@
+package nasa
+
+import (
+	"context"
+	"errors"
+	"io"
+	"math/rand"
+	"net"
+	"net/http"
+	"time"
+)
+
+// RetryPolicy defines how failed requests are retried.
+// Only transient failures are retried: timeouts, connection errors, 5xx responses
+// and 429 responses (after the Retry-After delay, if the API sets one).
+type RetryPolicy struct {
+	MaxAttempts int           // total attempts including the first; 1 or less disables retries
+	BaseDelay   time.Duration // delay before the first retry, doubles with each attempt
+	MaxDelay    time.Duration // maximum delay between attempts, 0 for no limit
+	Jitter      float64       // fraction (0-1) of each delay that is randomized
+
+	// OnRetry, if set, is called before sleeping for each retry with the number
+	// of the attempt that failed
+	OnRetry func(attempt int, err error, delay time.Duration)
+}
+
+// DefaultRetryPolicy is the RetryPolicy used by clients that don't set one
+var DefaultRetryPolicy = RetryPolicy{
+	MaxAttempts: 3,
+	BaseDelay:   500 * time.Millisecond,
+	MaxDelay:    30 * time.Second,
+	Jitter:      0.5,
+}
+
+// NoRetry disables retries
+var NoRetry = RetryPolicy{MaxAttempts: 1}
+
+// WithRetryPolicy sets the retry policy of the client's requests
+func WithRetryPolicy(p RetryPolicy) Option {
+	return func(c *Client) { c.retry = p }
+}
+
+// Do calls fn until it succeeds, returns an error that's not retryable,
+// or the attempts are used up. It stops early if ctx is done.
+func (p RetryPolicy) Do(ctx context.Context, fn func(attempt int) error) error {
+	for attempt := 1; ; attempt++ {
+		err := fn(attempt)
+		if err == nil || attempt >= p.MaxAttempts || ctx.Err() != nil || !Retryable(err) {
+			return err
+		}
+		delay, ok := p.delay(attempt, err)
+		if !ok {
+			return err
+		}
+		if p.OnRetry != nil {
+			p.OnRetry(attempt, err, delay)
+		}
+		t := time.NewTimer(delay)
+		select {
+		case <-ctx.Done():
+			t.Stop()
+			return ctx.Err()
+		case <-t.C:
+		}
+	}
+}
+
+// delay returns how long to wait after the failed attempt,
+// ok is false if the API asked to wait longer than MaxDelay
+func (p RetryPolicy) delay(attempt int, err error) (d time.Duration, ok bool) {
+	var apiErr *APIError
+	if errors.As(err, &apiErr) && apiErr.RetryAfter > 0 {
+		if p.MaxDelay > 0 && apiErr.RetryAfter > p.MaxDelay {
+			return 0, false
+		}
+		return apiErr.RetryAfter, true
+	}
+	d = p.BaseDelay << uint(attempt-1)
+	if d < 0 || (p.MaxDelay > 0 && d > p.MaxDelay) {
+		d = p.MaxDelay
+	}
+	if p.Jitter > 0 {
+		d -= time.Duration(p.Jitter * rand.Float64() * float64(d))
+	}
+	return d, true
+}
+
+// Retryable reports whether err is a transient failure worth retrying
+func Retryable(err error) bool {
+	var apiErr *APIError
+	if errors.As(err, &apiErr) {
+		return apiErr.StatusCode == http.StatusTooManyRequests ||
+			apiErr.StatusCode >= http.StatusInternalServerError
+	}
+	var netErr net.Error
+	if errors.As(err, &netErr) && netErr.Timeout() {
+		return true
+	}
+	var opErr *net.OpError
+	if errors.As(err, &opErr) {
+		return true
+	}
+	return errors.Is(err, io.ErrUnexpectedEOF) || errors.Is(err, io.EOF)
+}
@@ -0,0 +1,73 @@
+package nasa
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestRetryPolicy(t *testing.T) {
+	tests := []struct {
+		statuses []int // statuses returned by consecutive requests
+		calls    int
+		retried  int
+		err      error
+	}{
+		{[]int{http.StatusOK}, 1, 0, nil},
+		{[]int{http.StatusBadGateway, http.StatusOK}, 2, 1, nil},
+		{[]int{http.StatusTooManyRequests, http.StatusServiceUnavailable, http.StatusOK}, 3, 2, nil},
+		{[]int{http.StatusInternalServerError, http.StatusInternalServerError, http.StatusInternalServerError}, 3, 2, ErrUpstreamDown},
+		{[]int{http.StatusForbidden, http.StatusOK}, 1, 0, ErrInvalidKey},
+	}
+	for _, v := range tests {
+		var calls, retried int
+		ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			status := v.statuses[calls]
+			calls++
+			switch status {
+			case http.StatusOK:
+				fmt.Fprint(w, `{"date":"2017-05-11","title":"Test","url":"https://apod.nasa.gov/a.jpg"}`)
+			case http.StatusForbidden:
+				w.WriteHeader(status)
+				fmt.Fprint(w, `{"error":{"code":"API_KEY_INVALID","message":"An invalid api_key was supplied."}}`)
+			default:
+				w.WriteHeader(status)
+			}
+		}))
+		c := NewClient(WithBaseURL(ts.URL), WithRetryPolicy(RetryPolicy{
+			MaxAttempts: 3,
+			BaseDelay:   time.Millisecond,
+			Jitter:      0.5,
+			OnRetry:     func(attempt int, err error, delay time.Duration) { retried++ },
+		}))
+		_, err := c.APOD(time.Date(2017, 5, 11, 0, 0, 0, 0, time.UTC))
+		ts.Close()
+
+		if !errors.Is(err, v.err) {
+			t.Errorf("APOD returned wrong error for %v got %v, want %v", v.statuses, err, v.err)
+		}
+		if calls != v.calls {
+			t.Errorf("APOD made wrong number of requests for %v got %d, want %d", v.statuses, calls, v.calls)
+		}
+		if retried != v.retried {
+			t.Errorf("OnRetry called wrong number of times for %v got %d, want %d", v.statuses, retried, v.retried)
+		}
+	}
+}
+
+func TestRetryAfter(t *testing.T) {
+	p := RetryPolicy{MaxAttempts: 3, BaseDelay: time.Millisecond, MaxDelay: time.Minute}
+	d, ok := p.delay(1, &APIError{StatusCode: http.StatusTooManyRequests, RetryAfter: 10 * time.Second})
+	if !ok || d != 10*time.Second {
+		t.Errorf("delay ignored Retry-After got %s, want %s", d, 10*time.Second)
+	}
+	if _, ok := p.delay(1, &APIError{StatusCode: http.StatusTooManyRequests, RetryAfter: time.Hour}); ok {
+		t.Errorf("delay retries when Retry-After is longer than MaxDelay")
+	}
+	if d, _ := p.delay(20, errors.New("test")); d != time.Minute {
+		t.Errorf("delay not limited to MaxDelay got %s, want %s", d, time.Minute)
+	}
+}
@@ -0,0 +1,400 @@
+package nasa
+
+import (
+	"math"
+	"sort"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// titleBoost is how much more a match in the title counts than one in the explanation
+const titleBoost = 2.0
+
+// BM25 ranking parameters
+const (
+	bm25K1 = 1.2
+	bm25B  = 0.75
+)
+
+// SearchIndex is an inverted index for full-text search over APOD titles and explanations.
+// It's safe for concurrent searches, but not for searches concurrent with Add.
+type SearchIndex struct {
+	docs        []Image
+	dates       map[Date]int // doc of each date
+	title, expl fieldIndex
+}
+
+// fieldIndex indexes one field of the documents
+type fieldIndex struct {
+	postings map[string][]posting
+	lengths  []int // number of terms of each doc
+	total    int   // number of terms of all docs
+}
+
+// posting is a term's occurrences in a doc
+type posting struct {
+	doc       int
+	positions []int
+}
+
+// NewSearchIndex returns a SearchIndex of apods
+func NewSearchIndex(apods []Image) *SearchIndex {
+	ix := &SearchIndex{
+		dates: make(map[Date]int, len(apods)),
+		title: fieldIndex{postings: make(map[string][]posting)},
+		expl:  fieldIndex{postings: make(map[string][]posting)},
+	}
+	for _, apod := range apods {
+		ix.Add(apod)
+	}
+	return ix
+}
+
+// Add adds apod to the index, replacing the APOD of the same date
+func (ix *SearchIndex) Add(apod Image) {
+	if i, ok := ix.dates[apod.Date]; ok {
+		// keep the doc number, its old terms no longer match the new text
+		ix.title.remove(i)
+		ix.expl.remove(i)
+		ix.docs[i] = apod
+		ix.title.add(i, apod.Title)
+		ix.expl.add(i, searchText(apod))
+		return
+	}
+	i := len(ix.docs)
+	ix.docs = append(ix.docs, apod)
+	ix.dates[apod.Date] = i
+	ix.title.add(i, apod.Title)
+	ix.expl.add(i, searchText(apod))
+}
+
+// searchText returns the explanation of apod as indexed: followed by the catalogue
+// IDs of the objects it mentions without spaces, so "NGC4594" matches "NGC 4594"
+func searchText(apod Image) string {
+	text := apod.Explanation
+	for _, o := range catalogueObjects(text) {
+		if strings.Contains(o, " ") {
+			text += " " + strings.ReplaceAll(o, " ", "")
+		}
+	}
+	return text
+}
+
+// SearchIndex returns a SearchIndex of the APODs in the mirror
+func (m *Mirror) SearchIndex() *SearchIndex {
+	entries := m.Entries()
+	apods := make([]Image, len(entries))
+	for i, e := range entries {
+		apods[i] = e.Image
+	}
+	return NewSearchIndex(apods)
+}
+
+// Len returns the number of APODs in the index
+func (ix *SearchIndex) Len() int { return len(ix.docs) }
+
+func (f *fieldIndex) add(doc int, text string) {
+	terms := tokenize(text)
+	for len(f.lengths) <= doc {
+		f.lengths = append(f.lengths, 0)
+	}
+	f.lengths[doc] = len(terms)
+	f.total += len(terms)
+	positions := make(map[string][]int)
+	for i, t := range terms {
+		positions[t] = append(positions[t], i)
+	}
+	for t, pos := range positions {
+		// keep postings ordered by doc, docs replaced by Add are not the last one
+		ps := f.postings[t]
+		i := sort.Search(len(ps), func(i int) bool { return ps[i].doc >= doc })
+		ps = append(ps, posting{})
+		copy(ps[i+1:], ps[i:])
+		ps[i] = posting{doc, pos}
+		f.postings[t] = ps
+	}
+}
+
+func (f *fieldIndex) remove(doc int) {
+	for t, ps := range f.postings {
+		for i, p := range ps {
+			if p.doc == doc {
+				f.postings[t] = append(ps[:i:i], ps[i+1:]...)
+				break
+			}
+		}
+		if len(f.postings[t]) == 0 {
+			delete(f.postings, t)
+		}
+	}
+	f.total -= f.lengths[doc]
+	f.lengths[doc] = 0
+}
+
+// positions returns the positions of term in doc, nil if it doesn't occur
+func (f *fieldIndex) positions(term string, doc int) []int {
+	ps := f.postings[term]
+	i := sort.Search(len(ps), func(i int) bool { return ps[i].doc >= doc })
+	if i < len(ps) && ps[i].doc == doc {
+		return ps[i].positions
+	}
+	return nil
+}
+
+// score returns the BM25 score of term in doc
+func (f *fieldIndex) score(term string, doc, ndocs int) float64 {
+	tf := float64(len(f.positions(term, doc)))
+	if tf == 0 || f.total == 0 {
+		return 0
+	}
+	df := float64(len(f.postings[term]))
+	idf := math.Log(1 + (float64(ndocs)-df+0.5)/(df+0.5))
+	avg := float64(f.total) / float64(ndocs)
+	norm := 1 - bm25B + bm25B*float64(f.lengths[doc])/avg
+	return idf * tf * (bm25K1 + 1) / (tf + bm25K1*norm)
+}
+
+// hasPhrase reports whether the terms occur next to each other in doc
+func (f *fieldIndex) hasPhrase(terms []string, doc int) bool {
+	if len(terms) == 0 {
+		return false
+	}
+	for _, start := range f.positions(terms[0], doc) {
+		found := true
+		for i, t := range terms[1:] {
+			if !containsInt(f.positions(t, doc), start+i+1) {
+				found = false
+				break
+			}
+		}
+		if found {
+			return true
+		}
+	}
+	return false
+}
+
+func containsInt(sorted []int, n int) bool {
+	i := sort.SearchInts(sorted, n)
+	return i < len(sorted) && sorted[i] == n
+}
+
+// tokenize splits text into lower case terms of letters and digits
+func tokenize(text string) []string {
+	return strings.FieldsFunc(strings.ToLower(text), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+}
+
+// Query is a parsed search query
+type Query struct {
+	Terms   []string   // terms that must all occur
+	Phrases [][]string // phrases that must all occur, as terms
+	From    Date       // earliest APOD date, zero for any
+	To      Date       // latest APOD date, zero for any
+}
+
+// ParseQuery parses a search query: words, "quoted phrases" and the date filters
+// from:YYYY-MM-DD and to:YYYY-MM-DD. Invalid dates are searched for as words.
+func ParseQuery(s string) Query {
+	var q Query
+	for i, part := range strings.Split(s, `"`) {
+		if i%2 == 1 { // inside quotes
+			if terms := tokenize(part); len(terms) > 1 {
+				q.Phrases = append(q.Phrases, terms)
+			} else {
+				q.Terms = append(q.Terms, terms...)
+			}
+			continue
+		}
+		for _, word := range strings.Fields(part) {
+			lw := strings.ToLower(word)
+			if strings.HasPrefix(lw, "from:") {
+				if d, err := ParseDate(lw[len("from:"):]); err == nil {
+					q.From = d
+					continue
+				}
+			}
+			if strings.HasPrefix(lw, "to:") {
+				if d, err := ParseDate(lw[len("to:"):]); err == nil {
+					q.To = d
+					continue
+				}
+			}
+			q.Terms = append(q.Terms, tokenize(word)...)
+		}
+	}
+	return q
+}
+
+// IsEmpty reports whether the query has nothing to search for
+func (q Query) IsEmpty() bool { return len(q.Terms) == 0 && len(q.Phrases) == 0 }
+
+// SearchResult is an APOD matching a query
+type SearchResult struct {
+	Image
+	Score   float64 `json:"score"`
+	Snippet string  `json:"snippet"` // part of the explanation matching the query
+}
+
+// Search returns the APODs matching all of q's terms and phrases, best matches first,
+// at most limit of them (all if limit < 1). An empty query matches every APOD in its
+// date range, newest first.
+func (ix *SearchIndex) Search(q Query, limit int) []SearchResult {
+	var results []SearchResult
+	for _, doc := range ix.candidates(q) {
+		apod := ix.docs[doc]
+		if (!q.From.IsZero() && apod.Date.Before(q.From)) || (!q.To.IsZero() && apod.Date.After(q.To)) {
+			continue
+		}
+		score, ok := ix.score(q, doc)
+		if !ok {
+			continue
+		}
+		results = append(results, SearchResult{Image: apod, Score: score, Snippet: snippet(apod.Explanation, q)})
+	}
+	sort.SliceStable(results, func(i, j int) bool {
+		if results[i].Score != results[j].Score {
+			return results[i].Score > results[j].Score
+		}
+		return results[i].Date.After(results[j].Date)
+	})
+	if limit > 0 && len(results) > limit {
+		results = results[:limit]
+	}
+	return results
+}
+
+// candidates returns the docs that may match q: those with the query's first term
+func (ix *SearchIndex) candidates(q Query) []int {
+	var term string
+	switch {
+	case len(q.Terms) > 0:
+		term = q.Terms[0]
+	case len(q.Phrases) > 0:
+		term = q.Phrases[0][0]
+	default:
+		docs := make([]int, len(ix.docs))
+		for i := range docs {
+			docs[i] = i
+		}
+		return docs
+	}
+	seen := make(map[int]bool)
+	var docs []int
+	for _, ps := range [][]posting{ix.title.postings[term], ix.expl.postings[term]} {
+		for _, p := range ps {
+			if !seen[p.doc] {
+				seen[p.doc] = true
+				docs = append(docs, p.doc)
+			}
+		}
+	}
+	return docs
+}
+
+// score returns the score of doc for q, ok is false if doc doesn't match
+func (ix *SearchIndex) score(q Query, doc int) (score float64, ok bool) {
+	n := len(ix.docs)
+	for _, t := range q.Terms {
+		s := titleBoost*ix.title.score(t, doc, n) + ix.expl.score(t, doc, n)
+		if s == 0 {
+			return 0, false
+		}
+		score += s
+	}
+	for _, p := range q.Phrases {
+		inTitle := ix.title.hasPhrase(p, doc)
+		if !inTitle && !ix.expl.hasPhrase(p, doc) {
+			return 0, false
+		}
+		var s float64
+		for _, t := range p {
+			s += ix.expl.score(t, doc, n)
+			if inTitle {
+				s += titleBoost * ix.title.score(t, doc, n)
+			}
+		}
+		score += 1.5 * s // phrase matches are better than scattered terms
+	}
+	return score, true
+}
+
+// snippetLen is the approximate length of a search result snippet
+const snippetLen = 200
+
+// snippet returns the part of the text around the first match of q
+func snippet(text string, q Query) string {
+	// copied, appending to q.Terms could write to its spare capacity shared by concurrent searches
+	terms := append(append([]string(nil), q.Terms...), flatten(q.Phrases)...)
+	at := indexWords(text, terms)
+	if at < 0 {
+		at = 0
+	}
+	start := at - snippetLen/4
+	end := start + snippetLen
+	if end > len(text) {
+		end, start = len(text), len(text)-snippetLen
+	}
+	if start < 0 {
+		start, end = 0, snippetLen
+		if end > len(text) {
+			end = len(text)
+		}
+	}
+	// move in to word boundaries, or at least to whole characters
+	for start > 0 && start < at && text[start-1] != ' ' {
+		start++
+	}
+	for end < len(text) && end > at && text[end] != ' ' {
+		end--
+	}
+	for start < len(text) && !utf8.RuneStart(text[start]) {
+		start++
+	}
+	for end < len(text) && end > start && !utf8.RuneStart(text[end]) {
+		end--
+	}
+	s := strings.TrimSpace(text[start:end])
+	if start > 0 {
+		s = "..." + s
+	}
+	if end < len(text) {
+		s += "..."
+	}
+	return s
+}
+
+// indexWords returns the index in text of the first word starting with one of terms,
+// -1 if there's none. Words are lower cased one at a time, as lower casing can change
+// their length, so the index is into text itself.
+func indexWords(text string, terms []string) int {
+	start := -1
+	for i, r := range text + " " {
+		if unicode.IsLetter(r) || unicode.IsDigit(r) {
+			if start < 0 {
+				start = i
+			}
+			continue
+		}
+		if start >= 0 {
+			word := strings.ToLower(text[start:i])
+			for _, t := range terms {
+				if strings.HasPrefix(word, t) {
+					return start
+				}
+			}
+			start = -1
+		}
+	}
+	return -1
+}
+
+func flatten(phrases [][]string) []string {
+	var terms []string
+	for _, p := range phrases {
+		terms = append(terms, p...)
+	}
+	return terms
+}
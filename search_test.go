@@ -0,0 +1,115 @@
+package nasa
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+	"unicode/utf8"
+)
+
+func TestParseQuery(t *testing.T) {
+	tests := []struct {
+		s    string
+		want Query
+	}{
+		{"Horsehead", Query{Terms: []string{"horsehead"}}},
+		{`horsehead "dark molecular cloud" from:2010-01-01 to:2014-12-31`, Query{
+			Terms:   []string{"horsehead"},
+			Phrases: [][]string{{"dark", "molecular", "cloud"}},
+			From:    Date{2010, time.January, 1},
+			To:      Date{2014, time.December, 31},
+		}},
+		{`"M104" from:yesterday`, Query{Terms: []string{"m104", "from", "yesterday"}}},
+		{"", Query{}},
+	}
+	for _, v := range tests {
+		if got := ParseQuery(v.s); !reflect.DeepEqual(got, v.want) {
+			t.Errorf("ParseQuery(%q) returned wrong query got %+v, want %+v", v.s, got, v.want)
+		}
+	}
+}
+
+func TestSearchIndex(t *testing.T) {
+	apods := []Image{
+		{Date: Date{2013, time.November, 8}, Title: "The Horsehead Nebula",
+			Explanation: "The Horsehead Nebula in Orion is part of a large, dark, molecular cloud."},
+		{Date: Date{2017, time.May, 13}, Title: "M104: The Sombrero Galaxy",
+			Explanation: "The Sombrero galaxy, also known as NGC 4594, has a dark dust lane. Dark clouds abound."},
+		{Date: Date{2018, time.January, 2}, Title: "Orion Deep Field",
+			Explanation: "Nebulae in Orion, including the Horsehead, cloud the field."},
+	}
+	ix := NewSearchIndex(apods)
+	tests := []struct {
+		q    string
+		want []Date
+	}{
+		{"horsehead", []Date{apods[0].Date, apods[2].Date}}, // title matches rank first
+		{"orion nebula", []Date{apods[0].Date}},
+		{`"molecular cloud"`, []Date{apods[0].Date}},
+		{`"cloud the field"`, []Date{apods[2].Date}},
+		{`"dark cloud"`, nil},
+		{"ngc 4594", []Date{apods[1].Date}},
+		{"horsehead from:2014-01-01", []Date{apods[2].Date}},
+		{"horsehead to:2013-12-31", []Date{apods[0].Date}},
+		{"quasar", nil},
+	}
+	for _, v := range tests {
+		var got []Date
+		for _, r := range ix.Search(ParseQuery(v.q), 0) {
+			got = append(got, r.Date)
+		}
+		if !reflect.DeepEqual(got, v.want) {
+			t.Errorf("Search(%q) returned wrong APODs got %v, want %v", v.q, got, v.want)
+		}
+	}
+	if got := ix.Search(ParseQuery("dark"), 1); len(got) != 1 || got[0].Date != apods[1].Date {
+		t.Errorf("Search returned wrong limited results got %v, want the Sombrero galaxy", got)
+	}
+
+	// replacing an APOD removes its old text
+	ix.Add(Image{Date: apods[1].Date, Title: "Updated", Explanation: "Nothing to see"})
+	if got := ix.Search(ParseQuery("sombrero"), 0); len(got) != 0 || ix.Len() != 3 {
+		t.Errorf("Search returned a replaced APOD: %v", got)
+	}
+	if got := ix.Search(ParseQuery("updated"), 0); len(got) != 1 {
+		t.Errorf("Search did not find a replaced APOD: %v", got)
+	}
+}
+
+func TestSearchSnippet(t *testing.T) {
+	apod, err := ApodImage(time.Date(2013, 11, 8, 0, 0, 0, 0, time.UTC))
+	if err != nil {
+		t.Fatal(err)
+	}
+	res := NewSearchIndex([]Image{*apod}).Search(ParseQuery("sigma orionis"), 0)
+	if len(res) != 1 {
+		t.Fatalf("Search returned wrong number of results got %d, want %d", len(res), 1)
+	}
+	want := "...shape was first discovered on a photographic plate in the late 1800s. The red glow originates from hydrogen gas predominantly behind the nebula, ionized by the nearby bright star Sigma Orionis."
+	if res[0].Snippet != want {
+		t.Errorf("Search returned wrong snippet got %q, want %q", res[0].Snippet, want)
+	}
+
+	// the query is shared by concurrent searches, snippet must not write to it
+	terms := make([]string, 1, 4)
+	terms[0] = "horsehead"
+	snippet(apod.Explanation, Query{Terms: terms, Phrases: [][]string{{"sigma", "orionis"}}})
+	if spare := terms[:2][1]; spare != "" {
+		t.Errorf("snippet wrote %q to the spare capacity of the query's terms", spare)
+	}
+
+	// lower casing changes the length of some characters, e.g. İ and the Kelvin sign K
+	for _, v := range []struct {
+		text, query, want string
+	}{
+		{strings.Repeat("İ ", 200) + "hosted the eclipse.", "eclipse", "eclipse"},
+		{strings.Repeat("\u212a ", 100) + "is the Kelvin sign." + strings.Repeat(" c", 200), "kelvin", "Kelvin sign."},
+		{strings.Repeat("a ", 100) + "GROẞE Wolke " + strings.Repeat("b ", 100), "große", "GROẞE Wolke"},
+	} {
+		got := snippet(v.text, ParseQuery(v.query))
+		if !strings.Contains(got, v.want) || !utf8.ValidString(got) {
+			t.Errorf("snippet(%q) returned wrong snippet got %q, want one containing %q", v.query, got, v.want)
+		}
+	}
+}
@@ -1,12 +1,14 @@
 package nasa
 
 import (
+	"bytes"
+	"encoding/json"
+	"errors"
 	"fmt"
 	"html/template"
 	"log"
 	"net/http"
 	"strconv"
-	"strings"
 	"sync"
 	"time"
 )
@@ -14,6 +16,11 @@ import (
 // NewServer a http web-server that serves APOD pictures
 //     / - today's APOD
 //     /random-apod - returns a random APOD
+//     /quota - returns the NASA API quota remaining as JSON
+//     /apod/image - today's APOD picture, ?date=YYYY-MM-DD for another day, ?sd=1 for SD,
+//         ?w=&h=&fit=crop|smart|letterbox to fit it to a size, ?caption=bottom-left etc to caption it
+//     /search?q= - searches APODs, see ParseQuery. /search.json returns the results as JSON
+//     /apod/onthisday - the APODs of today's month and day in every year, ?date=MM-DD for another day
 //     TODO: /apod/YYYY-MM-DD - returns apod for specified date
 func NewServer(listenAddr string) (*http.Server, error) {
 	var err error
@@ -22,12 +29,18 @@ func NewServer(listenAddr string) (*http.Server, error) {
 		return nil, fmt.Errorf("unable to parse template: %v", err)
 	}
 	rh := &randomHandler{
+		pool:       NewRandomPool(randomPoolBatch),
 		lastUpdate: time.Now().Add(-10 * time.Hour),
 		cachedApod: &Image{},
 		tmpl:       tmpl,
 	}
 	http.HandleFunc("/", handleIndex)
 	http.Handle("/random-apod/", rh)
+	http.HandleFunc("/quota", handleQuota)
+	http.HandleFunc("/apod/image", handleImage)
+	http.HandleFunc("/search", handleSearch)
+	http.HandleFunc("/search.json", handleSearchJSON)
+	http.HandleFunc("/apod/onthisday", handleOnThisDay)
 
 	return &http.Server{
 		Addr:           listenAddr,
@@ -43,16 +56,167 @@ func handleIndex(w http.ResponseWriter, r *http.Request) {
 		http.NotFound(w, r)
 		return
 	}
-	apod, err := APODToday()
+	apod, err := APODTodayContext(r.Context())
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusServiceUnavailable)
+		http.Error(w, err.Error(), errorStatus(err))
 		return
 	}
 	TmplData{Apod: *apod}.Render(w)
 }
 
+func handleQuota(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	resp := struct {
+		Quota
+		Keys []KeyHealth `json:"keys"`
+	}{GetQuota(), GetKeyHealth()}
+	if err := json.NewEncoder(w).Encode(resp); err != nil {
+		log.Print(err)
+	}
+}
+
+// handleImage streams the picture of an APOD, downloaded from NASA
+func handleImage(w http.ResponseWriter, r *http.Request) {
+	d := LatestAPODDate()
+	if date := r.URL.Query().Get("date"); date != "" {
+		var err error
+		if d, err = ParseDate(date); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+	}
+	apod, err := APODDateContext(r.Context(), d)
+	if err != nil {
+		http.Error(w, err.Error(), errorStatus(err))
+		return
+	}
+	var opts []DownloadOption
+	if r.URL.Query().Get("sd") != "" {
+		opts = append(opts, DownloadSD())
+	}
+	if q := r.URL.Query(); q.Get("w") != "" || q.Get("h") != "" || q.Get("caption") != "" {
+		handleProcessedImage(w, r, *apod, opts)
+		return
+	}
+	tw := &trackingWriter{ResponseWriter: w, first: func() { setImageCache(w.Header(), d) }}
+	if _, err := DownloadImageContext(r.Context(), *apod, tw, opts...); err != nil {
+		if tw.wrote {
+			log.Printf("nasa: image download for %s failed midway: %v", d, err)
+			return
+		}
+		http.Error(w, err.Error(), errorStatus(err))
+	}
+}
+
+// maxFitSize is the largest width or height the server fits pictures to
+const maxFitSize = 4096
+
+// processing limits the pictures processed at once, each may take hundreds of MB
+var processing = make(chan struct{}, 4)
+
+// handleProcessedImage serves the picture of an APOD as JPEG, fitted to ?w= x ?h= with
+// ?fit= (letterbox by default) and captioned at ?caption=. Either size may be left out
+// to keep the aspect ratio.
+func handleProcessedImage(w http.ResponseWriter, r *http.Request, apod Image, opts []DownloadOption) {
+	q := r.URL.Query()
+	var size [2]int
+	for i, name := range []string{"w", "h"} {
+		v := q.Get(name)
+		if v == "" {
+			continue
+		}
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 1 || n > maxFitSize {
+			http.Error(w, fmt.Sprintf("invalid %s %q, should be 1 to %d", name, v, maxFitSize), http.StatusBadRequest)
+			return
+		}
+		size[i] = n
+	}
+	mode := FitLetterbox
+	if v := q.Get("fit"); v != "" {
+		var err error
+		if mode, err = ParseFitMode(v); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+	}
+	steps := []ProcessOption{ProcessFit(size[0], size[1], mode)}
+	if v := q.Get("caption"); v != "" {
+		pos, err := ParseCaptionPosition(v)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		steps = append(steps, ProcessCaption(apod, CaptionAt(pos)))
+	}
+	select {
+	case processing <- struct{}{}:
+		defer func() { <-processing }()
+	case <-r.Context().Done():
+		http.Error(w, r.Context().Err().Error(), http.StatusServiceUnavailable)
+		return
+	}
+	var pic, out bytes.Buffer
+	if _, err := DownloadImageContext(r.Context(), apod, &pic, opts...); err != nil {
+		http.Error(w, err.Error(), errorStatus(err))
+		return
+	}
+	if err := ProcessImage(&pic, &out, steps...); err != nil {
+		http.Error(w, err.Error(), http.StatusBadGateway) // e.g. a WebP picture, which can't be decoded
+		return
+	}
+	setImageCache(w.Header(), apod.Date)
+	w.Header().Set("Content-Type", "image/jpeg")
+	w.Header().Set("Content-Length", strconv.Itoa(out.Len()))
+	if _, err := out.WriteTo(w); err != nil {
+		log.Printf("nasa: unable to write processed image: %v", err)
+	}
+}
+
+// setImageCache lets clients cache the picture of the APOD of d, unless it's the
+// latest, which may still be replaced. Only set once the picture is being served.
+func setImageCache(h http.Header, d Date) {
+	if d != LatestAPODDate() {
+		h.Set("Cache-Control", "public, max-age=86400")
+	}
+}
+
+// trackingWriter records whether a response has been written to
+type trackingWriter struct {
+	http.ResponseWriter
+	wrote bool
+	first func() // called before the first write, e.g. to set headers
+}
+
+func (tw *trackingWriter) Write(p []byte) (int, error) {
+	if !tw.wrote && tw.first != nil {
+		tw.first()
+	}
+	tw.wrote = true
+	return tw.ResponseWriter.Write(p)
+}
+
+// errorStatus returns the http status to respond with for a failed NASA API call
+func errorStatus(err error) int {
+	switch {
+	case errors.Is(err, ErrRateLimited):
+		return http.StatusTooManyRequests
+	case errors.Is(err, ErrDateOutOfRange):
+		return http.StatusBadRequest
+	case errors.Is(err, ErrNoPicture):
+		return http.StatusNotFound
+	case errors.Is(err, ErrUnexpectedMimeType), errors.Is(err, ErrDownloadTooLarge):
+		return http.StatusBadGateway
+	}
+	return http.StatusServiceUnavailable
+}
+
+// randomPoolBatch is the number of random APODs the server fetches at once
+const randomPoolBatch = 50
+
 type randomHandler struct {
 	tmpl *template.Template
+	pool *RandomPool
 
 	mu         sync.RWMutex // protects the values below
 	lastUpdate time.Time
@@ -88,7 +252,7 @@ func (h *randomHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 
 	// Update if cached apod is older than a second
 	if time.Now().Sub(h.last()) > time.Second {
-		if newApod, err := RandomAPOD(); err == nil {
+		if newApod, err := h.pool.Next(r.Context()); err == nil {
 			if newApod.URL != "" {
 				apod = *newApod
 			}
@@ -125,20 +289,25 @@ type TmplData struct {
 	AutoReload         bool
 	Legacy             bool // legacy browser does not support new reload
 	IsYoutube          bool
+	IsVideo            bool   // video (or other media) APODs are embedded instead of displayed as images
+	Video              *Video // set for video APODs
+	IsOther            bool   // other media without a url e.g. interactive pages, linked to on apod.nasa.gov
 	AutoReloadInterval int
 }
 
 // Render returns an html to the responsewriter based on the template data
 func (td TmplData) Render(wr http.ResponseWriter) {
-	if strings.Contains(td.Apod.URL, "youtube") {
-		td.IsYoutube = true
-		if i := strings.Index(td.Apod.URL, "?"); i > 1 {
-			td.Apod.URL = td.Apod.URL[:i]
-		}
+	if v, ok := td.Apod.Video(); ok {
+		td.Video = &v
+		td.IsVideo = true
+		td.IsYoutube = v.Provider == ProviderYouTube
 	}
 	if td.Apod.URL == "" && td.Apod.HDURL == "" {
-		http.Error(wr, "NASA API currently unavailable, it's experiencing downtime :(", http.StatusServiceUnavailable)
-		return
+		if td.Apod.MediaType != MediaOther {
+			http.Error(wr, "NASA API currently unavailable, it's experiencing downtime :(", http.StatusServiceUnavailable)
+			return
+		}
+		td.IsOther, td.IsVideo, td.Video = true, false, nil
 	}
 	if err := tmpl.Execute(wr, td); err != nil {
 		log.Print(err)
@@ -185,12 +354,21 @@ function TimedRefresh( t ) { setTimeout("location.reload(true);", t); }
 {{end}}
 <body {{if and .AutoReload .Legacy}}onload="javascript:TimedRefresh({{.AutoReloadInterval}}*1000)"{{end}}>
 <div id="imgwrap">
-{{if .IsYoutube}}
+{{if .IsVideo}}
 <div style="position: fixed; z-index: -99; width: 100%; height: 100%">
-<iframe frameborder="0" height="100%" width="100%" 
-src="{{.Apod.URL}}?autoplay=1&controls=0&showinfo=0&autohide=1">
+{{if .Video.IsFile}}
+<video src="{{.Video.EmbedURL}}" {{with .Video.ThumbnailURL}}poster="{{.}}"{{end}} autoplay muted loop playsinline style="width:100%; height:100%; object-fit:contain"></video>
+{{else if and .Video.IsPage .Video.ThumbnailURL}}
+<a href="{{.Video.URL}}"><img src="{{.Video.ThumbnailURL}}" id="bg" alt="{{.Apod.Title}}" /></a>
+{{else}}
+<iframe frameborder="0" height="100%" width="100%" allowfullscreen src="{{.Video.AutoplayURL}}">
 </iframe>
+{{end}}
 </div>
+{{else if .IsOther}}
+{{with .Apod.ThumbnailURL}}<a href="{{$.Apod.PageURL}}"><img src="{{.}}" id="bg" alt="{{$.Apod.Title}}" /></a>
+{{else}}<p style="text-align:center; margin-top:20%"><a href="{{.Apod.PageURL}}" style="color:#fff; font-size:1.5em">{{.Apod.Title}}: view this APOD on apod.nasa.gov</a></p>
+{{end}}
 {{else}}
 <img src="{{if .SD}}{{.Apod.URL}}{{else}}{{.Apod.HDURL}}{{end}}" id="bg" alt="{{.Apod.Title}}" />
 {{end}}
@@ -199,9 +377,11 @@ src="{{.Apod.URL}}?autoplay=1&controls=0&showinfo=0&autohide=1">
 <div id="explanation">
 <h4>{{.Apod.Title}}</h4>
 <p>{{.Apod.Explanation}}</p>
-<p>NASA Astronomy Picture of the Day {{.Apod.Date}} <a href="{{.Apod.HDURL}}" style="display:inline-block; color:#efefef"><i>Open Image in HD</i></a> </p>
+{{with .Apod.ParsedExplanation.Objects}}<p><small>Objects: {{range $i, $o := .}}{{if $i}}, {{end}}<a href="/search?q={{$o}}" style="color:#efefef">{{$o}}</a>{{end}}</small></p>{{end}}
+<p>NASA Astronomy Picture of the Day {{.Apod.Date}} {{if .IsVideo}}<a href="{{.Video.URL}}" style="display:inline-block; color:#efefef"><i>Open Video</i></a>{{else if .IsOther}}<a href="{{.Apod.PageURL}}" style="display:inline-block; color:#efefef"><i>Open on apod.nasa.gov</i></a>{{else}}<a href="{{.Apod.HDURL}}" style="display:inline-block; color:#efefef"><i>Open Image in HD</i></a>{{end}} </p>
 </div>
 <h4>{{.Apod.Title}}</h4>
+<p class="credit"><small>{{.Apod.Credit}}</small></p>
 <p style="text-align:right">
 View in fullscreen (F11) for best experience &#9786;.
 <i>Reload random pic every <a href="/random-apod/?auto=1&interval=60" style="color:#fff">1 min</a>, <a href="/random-apod/?auto=1&interval=600" style="color:#fff">10 min</a></i>
@@ -0,0 +1,114 @@
+package nasa
+
+import (
+	"context"
+	"html/template"
+	"log"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// onThisDay caches the APODs of each month and day served by /apod/onthisday,
+// until the next APOD is published
+var onThisDay struct {
+	mu     sync.Mutex // protects the following
+	latest Date
+	apods  map[string][]Image // by MM-DD
+}
+
+// onThisDayAPODs returns the APODs of month and day in every year, cached for the day
+func onThisDayAPODs(ctx context.Context, month time.Month, day int) ([]Image, error) {
+	key := NewDate(2000, month, day).Time(time.UTC).Format("01-02")
+	latest := LatestAPODDate()
+	onThisDay.mu.Lock()
+	if onThisDay.latest != latest {
+		onThisDay.latest, onThisDay.apods = latest, make(map[string][]Image)
+	}
+	apods, ok := onThisDay.apods[key]
+	onThisDay.mu.Unlock()
+	if ok {
+		return apods, nil
+	}
+	apods, err := OnThisDayContext(ctx, month, day)
+	if err != nil {
+		return apods, err // not cached, failed years are fetched again next time
+	}
+	onThisDay.mu.Lock()
+	if onThisDay.latest == latest {
+		onThisDay.apods[key] = apods
+	}
+	onThisDay.mu.Unlock()
+	return apods, nil
+}
+
+// onThisDayData is the data of the on this day page
+type onThisDayData struct {
+	Day   string // e.g. June 16
+	Date  string // MM-DD
+	APODs []Image
+	Err   error // years that failed, if some APODs were found
+}
+
+// handleOnThisDay serves the APODs of ?date=MM-DD (by default the latest APOD's) in every year
+func handleOnThisDay(w http.ResponseWriter, r *http.Request) {
+	latest := LatestAPODDate()
+	month, day := latest.Month, latest.Day
+	if v := r.URL.Query().Get("date"); v != "" {
+		t, err := time.Parse("01-02", v)
+		if err != nil {
+			http.Error(w, "invalid date "+v+", should be MM-DD", http.StatusBadRequest)
+			return
+		}
+		month, day = t.Month(), t.Day()
+	}
+	apods, err := onThisDayAPODs(r.Context(), month, day)
+	if err != nil && len(apods) == 0 {
+		http.Error(w, err.Error(), errorStatus(err))
+		return
+	}
+	if err != nil {
+		log.Printf("nasa: on this day %s %d: %v", month, day, err)
+	}
+	d := NewDate(2000, month, day).Time(time.UTC)
+	data := onThisDayData{Day: d.Format("January 2"), Date: d.Format("01-02"), APODs: apods, Err: err}
+	if err := onThisDayTmpl.Execute(w, data); err != nil {
+		log.Print(err)
+	}
+}
+
+var onThisDayTmpl = template.Must(template.New("onthisday").Parse(onThisDayTmplHTML))
+
+const onThisDayTmplHTML = `<!DOCTYPE html>
+<html lang="en">
+<meta charset="UTF-8">
+<title>{{.Day}} - NASA Astronomy Pictures of the Day on this day</title>
+<meta name="viewport" content="width=device-width,initial-scale=1">
+<style>
+body{background-color:#000; color:#fff; font-family:sans-serif; max-width:60em; margin:0 auto; padding:10px}
+a{color:#9cf}
+.apod{display:flex; gap:1em; margin:1.5em 0}
+.apod img{width:200px; height:150px; object-fit:cover; flex-shrink:0}
+.apod small{color:#aaa}
+</style>
+<body>
+<h3><a href="/">NASA Astronomy Picture of the Day</a> on {{.Day}}</h3>
+<form action="/apod/onthisday">
+<input type="text" name="date" value="{{.Date}}" placeholder="MM-DD" size="5" pattern="[0-9]{2}-[0-9]{2}">
+<input type="submit" value="Go">
+</form>
+{{with .Err}}<p><small>Some years could not be loaded: {{.}}</small></p>{{end}}
+{{range .APODs}}
+<div class="apod">
+{{if .IsVideo}}{{with .ThumbnailURL}}<img src="{{.}}" alt="" loading="lazy">{{end}}{{else if eq .MediaType "image"}}<img src="{{.URL}}" alt="" loading="lazy">{{end}}
+<div>
+<h4>{{.Date.Year}}: <a href="{{.PageURL}}">{{.Title}}</a></h4>
+<p>{{.ParsedExplanation.Summary}}</p>
+<small><a href="/apod/image?date={{.Date}}">{{.Date}}</a> &middot; {{.Credit}}</small>
+</div>
+</div>
+{{else}}
+<p>No APODs found.</p>
+{{end}}
+</body>
+</html>`
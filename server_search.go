@@ -0,0 +1,131 @@
+package nasa
+
+import (
+	"context"
+	"encoding/json"
+	"html/template"
+	"log"
+	"net/http"
+	"strconv"
+	"sync"
+)
+
+// SearchIndexFunc returns the index searched by the server's /search pages. By default
+// the APODs of the last year are fetched and indexed, set it to search e.g. a Mirror.
+var SearchIndexFunc = recentSearchIndex
+
+// searchDays is the number of days of APODs searched by default
+const searchDays = 365
+
+// defaultSearchLimit is the number of search results returned unless ?limit= is set
+const defaultSearchLimit = 20
+
+var recent struct {
+	mu     sync.Mutex // protects the following
+	latest Date
+	ix     *SearchIndex
+}
+
+// recentSearchIndex returns an index of the last year's APODs, updated daily
+func recentSearchIndex(ctx context.Context) (*SearchIndex, error) {
+	recent.mu.Lock()
+	defer recent.mu.Unlock()
+	latest := LatestAPODDate()
+	if recent.ix != nil && recent.latest == latest {
+		return recent.ix, nil
+	}
+	start := latest.AddDays(-searchDays + 1)
+	apods, err := APODRangeContext(ctx, start.Time(apodLocation), latest.Time(apodLocation))
+	if err != nil {
+		return nil, err
+	}
+	recent.ix, recent.latest = NewSearchIndex(apods), latest
+	return recent.ix, nil
+}
+
+// searchData is the data of the search page and JSON response
+type searchData struct {
+	Query   string         `json:"query"`
+	Total   int            `json:"total"` // APODs searched
+	Results []SearchResult `json:"results"`
+}
+
+// search runs the search of the request's ?q= query
+func search(r *http.Request) (*searchData, error) {
+	ix, err := SearchIndexFunc(r.Context())
+	if err != nil {
+		return nil, err
+	}
+	limit := defaultSearchLimit
+	if n, err := strconv.Atoi(r.URL.Query().Get("limit")); err == nil && n > 0 {
+		limit = n
+	}
+	sd := &searchData{Query: r.URL.Query().Get("q"), Total: ix.Len()}
+	if q := ParseQuery(sd.Query); !q.IsEmpty() {
+		sd.Results = ix.Search(q, limit)
+	}
+	return sd, nil
+}
+
+// handleSearch serves the search page
+func handleSearch(w http.ResponseWriter, r *http.Request) {
+	sd, err := search(r)
+	if err != nil {
+		http.Error(w, err.Error(), errorStatus(err))
+		return
+	}
+	if err := searchTmpl.Execute(w, sd); err != nil {
+		log.Print(err)
+	}
+}
+
+// handleSearchJSON serves search results as JSON
+func handleSearchJSON(w http.ResponseWriter, r *http.Request) {
+	sd, err := search(r)
+	if err != nil {
+		http.Error(w, err.Error(), errorStatus(err))
+		return
+	}
+	if sd.Results == nil {
+		sd.Results = []SearchResult{}
+	}
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(sd); err != nil {
+		log.Print(err)
+	}
+}
+
+var searchTmpl = template.Must(template.New("search").Parse(searchTmplHTML))
+
+const searchTmplHTML = `<!DOCTYPE html>
+<html lang="en">
+<meta charset="UTF-8">
+<title>{{with .Query}}{{.}} - {{end}}Search NASA Astronomy Pictures of the Day</title>
+<meta name="viewport" content="width=device-width,initial-scale=1">
+<style>
+body{background-color:#000; color:#fff; font-family:sans-serif; max-width:50em; margin:0 auto; padding:10px}
+a{color:#9cf}
+input[type=search]{width:70%; padding:5px}
+.result{margin:1.5em 0}
+.result small{color:#aaa}
+</style>
+<body>
+<h3><a href="/">NASA Astronomy Picture of the Day</a></h3>
+<form action="/search">
+<input type="search" name="q" value="{{.Query}}" placeholder="horsehead &quot;dark nebula&quot; from:2010-01-01" autofocus>
+<input type="submit" value="Search">
+</form>
+{{if .Query}}
+<p><small>{{len .Results}} results from {{.Total}} APODs</small></p>
+{{range .Results}}
+<div class="result">
+<a href="{{.PageURL}}"><b>{{.Title}}</b></a> <small>{{.Date}}</small>
+<p>{{.Snippet}}</p>
+<small><a href="/apod/image?date={{.Date}}">Picture</a> &middot; {{.Credit}}</small>
+</div>
+{{else}}
+<p>No APODs found.</p>
+{{end}}
+{{end}}
+</body>
+</html>`
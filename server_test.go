@@ -1,12 +1,15 @@
 package nasa
 
 import (
+	"context"
 	"html/template"
 	"net/http"
 	"net/http/httptest"
 	"strings"
 	"testing"
 	"time"
+
+	"github.com/peteretelej/nasa/nasatest"
 )
 
 type httpTestList struct {
@@ -65,6 +68,7 @@ func TestRandomHandler(t *testing.T) {
 		}
 		rr := httptest.NewRecorder()
 		handler := &randomHandler{
+			pool:       NewRandomPool(10),
 			lastUpdate: time.Now().Add(-10 * time.Hour),
 			cachedApod: &Image{},
 			tmpl:       tmpl,
@@ -80,3 +84,194 @@ func TestRandomHandler(t *testing.T) {
 	}
 
 }
+
+func TestHandleQuota(t *testing.T) {
+	req, err := http.NewRequest("GET", "/quota", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	rr := httptest.NewRecorder()
+	http.HandlerFunc(handleQuota).ServeHTTP(rr, req)
+	if rr.Code != http.StatusOK {
+		t.Errorf("handleQuota returned wrong status got %d, want %d", rr.Code, http.StatusOK)
+	}
+	if !strings.Contains(rr.Body.String(), `"remaining"`) {
+		t.Errorf("handleQuota returned body missing remaining quota")
+	}
+}
+
+func TestHandleImage(t *testing.T) {
+	testList := []httpTestList{
+		{"GET", "/apod/image?date=2017-05-11", http.StatusOK, "image/jpeg"},
+		{"GET", "/apod/image?date=2017-05-11&sd=1", http.StatusOK, "image/jpeg"},
+		{"GET", "/apod/image?date=2017-05-12", http.StatusOK, "image/jpeg"}, // video thumbnail
+		{"GET", "/apod/image?date=2017-13-01", http.StatusBadRequest, ""},
+		{"GET", "/apod/image?date=1990-01-01", http.StatusBadRequest, ""},
+		{"GET", "/apod/image?date=2017-05-11&w=640&h=360&fit=smart", http.StatusOK, "image/jpeg"},
+		{"GET", "/apod/image?date=2017-05-11&w=200", http.StatusOK, "image/jpeg"},
+		{"GET", "/apod/image?date=2017-05-11&w=640&h=360&fit=stretch", http.StatusBadRequest, ""},
+		{"GET", "/apod/image?date=2017-05-11&w=0", http.StatusBadRequest, ""},
+		{"GET", "/apod/image?date=2017-05-11&sd=1&caption=top-right", http.StatusOK, "image/jpeg"},
+		{"GET", "/apod/image?date=2017-05-11&caption=middle", http.StatusBadRequest, ""},
+		{"GET", "/apod/image?date=2017-05-11&h=100000", http.StatusBadRequest, ""},
+		{"GET", "/apod/image?date=2003-03-03", http.StatusServiceUnavailable, ""},       // picture missing from the image host
+		{"GET", "/apod/image?date=2003-03-03&w=200", http.StatusServiceUnavailable, ""}, // as above, processed
+	}
+	fakeAPI.AddAPOD(nasatest.APOD{Date: "2003-03-03", Title: "Missing", MediaType: "image", URL: fakeAPI.URL + "/image/bad", ServiceVersion: "v1"})
+	for _, v := range testList {
+		req, err := http.NewRequest(v.method, v.path, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		rr := httptest.NewRecorder()
+		http.HandlerFunc(handleImage).ServeHTTP(rr, req)
+		if rr.Code != v.code {
+			t.Errorf("handleImage %s returned wrong status got %d, want %d", v.path, rr.Code, v.code)
+		}
+		if v.contains != "" && rr.Header().Get("Content-Type") != v.contains {
+			t.Errorf("handleImage %s returned wrong content type got %s, want %s", v.path, rr.Header().Get("Content-Type"), v.contains)
+		}
+		// past pictures are cacheable, errors aren't
+		if cached := rr.Header().Get("Cache-Control") != ""; cached != (rr.Code == http.StatusOK) {
+			t.Errorf("handleImage %s returned status %d with Cache-Control %q", v.path, rr.Code, rr.Header().Get("Cache-Control"))
+		}
+	}
+
+	// pictures wait their turn to be processed, until the request is canceled
+	for i := 0; i < cap(processing); i++ {
+		processing <- struct{}{}
+	}
+	defer func() {
+		for i := 0; i < cap(processing); i++ {
+			<-processing
+		}
+	}()
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	req := httptest.NewRequest("GET", "/apod/image?date=2017-05-11&w=200", nil).WithContext(ctx)
+	rr := httptest.NewRecorder()
+	handleProcessedImage(rr, req, Image{MediaType: MediaImage, URL: fakeAPI.URL + "/image/2017-05-11.jpg"}, nil)
+	if rr.Code != http.StatusServiceUnavailable {
+		t.Errorf("handleImage with every processing slot taken returned wrong status got %d, want %d", rr.Code, http.StatusServiceUnavailable)
+	}
+}
+
+func TestHandleSearch(t *testing.T) {
+	defer func(f func(context.Context) (*SearchIndex, error)) { SearchIndexFunc = f }(SearchIndexFunc)
+	apod, err := ApodImage(time.Date(2013, 11, 8, 0, 0, 0, 0, time.UTC))
+	if err != nil {
+		t.Fatal(err)
+	}
+	ix := NewSearchIndex([]Image{*apod})
+	SearchIndexFunc = func(context.Context) (*SearchIndex, error) { return ix, nil }
+
+	testList := []struct {
+		httpTestList
+		handler http.HandlerFunc
+	}{
+		{httpTestList{"GET", "/search", http.StatusOK, `name="q"`}, handleSearch},
+		{httpTestList{"GET", "/search?q=horsehead", http.StatusOK, "The Horsehead Nebula"}, handleSearch},
+		{httpTestList{"GET", "/search?q=quasar", http.StatusOK, "No APODs found"}, handleSearch},
+		{httpTestList{"GET", "/search.json?q=%22dark+molecular+cloud%22", http.StatusOK, `"title":"The Horsehead Nebula"`}, handleSearchJSON},
+		{httpTestList{"GET", "/search.json?q=quasar", http.StatusOK, `"results":[]`}, handleSearchJSON},
+	}
+	for _, v := range testList {
+		req, err := http.NewRequest(v.method, v.path, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		rr := httptest.NewRecorder()
+		v.handler.ServeHTTP(rr, req)
+		if rr.Code != v.code {
+			t.Errorf("search %s returned wrong status got %d, want %d", v.path, rr.Code, v.code)
+		}
+		if !strings.Contains(rr.Body.String(), v.contains) {
+			t.Errorf("search %s returned body missing wanted text: %s", v.path, v.contains)
+		}
+	}
+}
+
+func TestHandleOnThisDay(t *testing.T) {
+	testList := []struct {
+		httpTestList
+		handler http.HandlerFunc
+	}{
+		{httpTestList{"GET", "/apod/onthisday?date=06-16", http.StatusOK, "1995-06-16"}, handleOnThisDay},
+		{httpTestList{"GET", "/apod/onthisday", http.StatusOK, LatestAPODDate().String()}, handleOnThisDay},
+		{httpTestList{"GET", "/apod/onthisday?date=02-30", http.StatusBadRequest, "MM-DD"}, handleOnThisDay},
+		{httpTestList{"GET", "/apod/onthisday?date=june", http.StatusBadRequest, "MM-DD"}, handleOnThisDay},
+	}
+	for _, v := range testList {
+		req, err := http.NewRequest(v.method, v.path, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		rr := httptest.NewRecorder()
+		v.handler.ServeHTTP(rr, req)
+		if rr.Code != v.code {
+			t.Errorf("on this day %s returned wrong status got %d, want %d", v.path, rr.Code, v.code)
+		}
+		if !strings.Contains(rr.Body.String(), v.contains) {
+			t.Errorf("on this day %s returned body missing wanted text: %s", v.path, v.contains)
+		}
+	}
+}
+
+func TestRecentSearchIndex(t *testing.T) {
+	ix, err := recentSearchIndex(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ix.Len() != searchDays {
+		t.Errorf("recentSearchIndex indexed wrong number of APODs got %d, want %d", ix.Len(), searchDays)
+	}
+}
+
+func TestRenderCreditsAndVideos(t *testing.T) {
+	var err error
+	tmpl, err = template.New("tmpl").Parse(tmplHTML)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		apod     Image
+		contains []string
+		missing  string
+	}{
+		{
+			Image{Title: "Sombrero", URL: "a.jpg", HDURL: "a-hd.jpg", MediaType: MediaImage, Copyright: "Mark Hanson"},
+			[]string{"Copyright: Mark Hanson", `<img src="a-hd.jpg"`},
+			"<iframe",
+		},
+		{
+			Image{Title: "Filament", URL: "https://player.vimeo.com/video/123", MediaType: MediaVideo},
+			[]string{"Public Domain", `src="https://player.vimeo.com/video/123?autoplay=1`},
+			"<img",
+		},
+		{
+			Image{Title: "Eclipse", URL: "https://apod.nasa.gov/apod/image/eclipse.mp4", MediaType: MediaVideo, ThumbnailURL: "thumb.jpg"},
+			[]string{`<video src="https://apod.nasa.gov/apod/image/eclipse.mp4"`, `poster="thumb.jpg"`},
+			"<iframe",
+		},
+		{
+			Image{Date: Date{2018, time.July, 9}, Title: "Interactive Earth", Explanation: "Explore it.", MediaType: MediaOther, Copyright: "Jane Doe"},
+			[]string{`href="https://apod.nasa.gov/apod/ap180709.html"`, "Explore it.", "Copyright: Jane Doe"},
+			"unavailable",
+		},
+	}
+	for _, v := range tests {
+		rr := httptest.NewRecorder()
+		TmplData{Apod: v.apod}.Render(rr)
+		if rr.Code != http.StatusOK {
+			t.Errorf("Render for %s returned wrong status got %d, want %d", v.apod.Title, rr.Code, http.StatusOK)
+		}
+		for _, want := range v.contains {
+			if !strings.Contains(rr.Body.String(), want) {
+				t.Errorf("Render for %s missing expected text: %s", v.apod.Title, want)
+			}
+		}
+		if strings.Contains(rr.Body.String(), v.missing) {
+			t.Errorf("Render for %s has unexpected text: %s", v.apod.Title, v.missing)
+		}
+	}
+}
@@ -0,0 +1,137 @@
+package nasa
+
+import (
+	"net/url"
+	"path"
+	"strings"
+)
+
+// Video providers
+const (
+	ProviderYouTube = "youtube"
+	ProviderVimeo   = "vimeo"
+	ProviderFile    = "file" // direct link to a video file e.g. mp4
+	ProviderPage    = "page" // any other page e.g. an interactive APOD
+)
+
+// Video describes the video (or other non image media) of an APOD
+type Video struct {
+	Provider     string // ProviderYouTube, ProviderVimeo, ProviderFile or ProviderPage
+	ID           string // provider's video id, empty for files and pages
+	URL          string // url returned by the API
+	EmbedURL     string // url to embed in an iframe, or the file url for a <video>
+	ThumbnailURL string // preview image, may be empty
+}
+
+// videoExts are the extensions of video files linked to directly
+var videoExts = map[string]bool{
+	".mp4": true, ".m4v": true, ".webm": true, ".ogv": true, ".ogg": true, ".mov": true,
+}
+
+// ParseVideoURL classifies the video at rawurl, detecting its provider, id and embed url
+func ParseVideoURL(rawurl string) Video {
+	v := Video{Provider: ProviderPage, URL: rawurl, EmbedURL: rawurl}
+	u, err := url.Parse(rawurl)
+	if err != nil {
+		return v
+	}
+	host := strings.TrimPrefix(strings.ToLower(u.Hostname()), "www.")
+	parts := strings.Split(strings.Trim(u.Path, "/"), "/")
+	switch {
+	case host == "youtu.be":
+		v.ID = parts[0]
+	case host == "youtube.com" || host == "m.youtube.com" || host == "youtube-nocookie.com":
+		switch {
+		case len(parts) > 1 && (parts[0] == "embed" || parts[0] == "v" || parts[0] == "shorts"):
+			v.ID = parts[1]
+		case parts[0] == "watch":
+			v.ID = u.Query().Get("v")
+		}
+	case host == "vimeo.com" || host == "player.vimeo.com":
+		for _, p := range parts {
+			if p != "" && strings.Trim(p, "0123456789") == "" {
+				v.ID = p
+				break
+			}
+		}
+		if v.ID != "" {
+			v.Provider = ProviderVimeo
+			v.EmbedURL = "https://player.vimeo.com/video/" + v.ID
+		}
+		return v
+	default:
+		if videoExts[strings.ToLower(path.Ext(u.Path))] {
+			v.Provider = ProviderFile
+		}
+		return v
+	}
+	if v.ID == "" {
+		return v
+	}
+	v.Provider = ProviderYouTube
+	v.EmbedURL = "https://www.youtube.com/embed/" + v.ID
+	if start := u.Query().Get("start"); start != "" {
+		v.EmbedURL += "?start=" + url.QueryEscape(start)
+	} else if t := u.Query().Get("t"); t != "" {
+		v.EmbedURL += "?start=" + url.QueryEscape(strings.TrimSuffix(t, "s"))
+	}
+	v.ThumbnailURL = "https://img.youtube.com/vi/" + v.ID + "/hqdefault.jpg"
+	return v
+}
+
+// AutoplayURL returns the embed url with the provider's muted autoplay options set,
+// for displaying the video as a background
+func (v Video) AutoplayURL() string {
+	u, err := url.Parse(v.EmbedURL)
+	if err != nil {
+		return v.EmbedURL
+	}
+	q := u.Query()
+	switch v.Provider {
+	case ProviderYouTube:
+		q.Set("autoplay", "1")
+		q.Set("mute", "1")
+		q.Set("controls", "0")
+		q.Set("loop", "1")
+		q.Set("playlist", v.ID) // needed for loop to work
+	case ProviderVimeo:
+		q.Set("autoplay", "1")
+		q.Set("muted", "1")
+		q.Set("background", "1")
+	default:
+		return v.EmbedURL
+	}
+	u.RawQuery = q.Encode()
+	return u.String()
+}
+
+// IsFile reports whether the video is a file that can be played with a <video> tag
+func (v Video) IsFile() bool { return v.Provider == ProviderFile }
+
+// IsPage reports whether the media is a page rather than a video
+func (v Video) IsPage() bool { return v.Provider == ProviderPage }
+
+// Video returns the video of the APOD, ok is false if the APOD is an image.
+// The thumbnail returned by the API is preferred over the provider's.
+func (ni Image) Video() (v Video, ok bool) {
+	if ni.MediaType == MediaImage || (ni.MediaType == "" && !strings.Contains(ni.URL, "youtube")) {
+		return Video{}, false
+	}
+	v = ParseVideoURL(ni.URL)
+	if ni.ThumbnailURL != "" {
+		v.ThumbnailURL = ni.ThumbnailURL
+	}
+	return v, true
+}
+
+// PictureURL returns the url of a still picture of the APOD: the HD or SD image
+// for images, or the video's thumbnail for videos. Empty if there's none.
+func (ni Image) PictureURL(hd bool) string {
+	if v, ok := ni.Video(); ok {
+		return v.ThumbnailURL
+	}
+	if hd && ni.HDURL != "" {
+		return ni.HDURL
+	}
+	return ni.URL
+}
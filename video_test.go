@@ -0,0 +1,58 @@
+package nasa
+
+import "testing"
+
+func TestParseVideoURL(t *testing.T) {
+	tests := []struct {
+		url      string
+		provider string
+		id       string
+		embed    string
+	}{
+		{"https://www.youtube.com/embed/GrtyqSc9rW0?rel=0", ProviderYouTube, "GrtyqSc9rW0", "https://www.youtube.com/embed/GrtyqSc9rW0"},
+		{"https://www.youtube.com/embed/GrtyqSc9rW0?start=30", ProviderYouTube, "GrtyqSc9rW0", "https://www.youtube.com/embed/GrtyqSc9rW0?start=30"},
+		{"https://www.youtube.com/watch?v=GrtyqSc9rW0&t=15s", ProviderYouTube, "GrtyqSc9rW0", "https://www.youtube.com/embed/GrtyqSc9rW0?start=15"},
+		{"https://youtu.be/GrtyqSc9rW0", ProviderYouTube, "GrtyqSc9rW0", "https://www.youtube.com/embed/GrtyqSc9rW0"},
+		{"https://player.vimeo.com/video/123456789?title=0", ProviderVimeo, "123456789", "https://player.vimeo.com/video/123456789"},
+		{"https://vimeo.com/123456789", ProviderVimeo, "123456789", "https://player.vimeo.com/video/123456789"},
+		{"https://apod.nasa.gov/apod/image/1901/eclipse.MP4", ProviderFile, "", "https://apod.nasa.gov/apod/image/1901/eclipse.MP4"},
+		{"https://apod.nasa.gov/apod/image/1901/interactive.html", ProviderPage, "", "https://apod.nasa.gov/apod/image/1901/interactive.html"},
+	}
+	for _, v := range tests {
+		got := ParseVideoURL(v.url)
+		if got.Provider != v.provider || got.ID != v.id || got.EmbedURL != v.embed {
+			t.Errorf("ParseVideoURL(%q) got %s %q %s, want %s %q %s",
+				v.url, got.Provider, got.ID, got.EmbedURL, v.provider, v.id, v.embed)
+		}
+	}
+}
+
+func TestImagePictureURL(t *testing.T) {
+	tests := []struct {
+		apod Image
+		hd   string
+		sd   string
+	}{
+		{Image{MediaType: MediaImage, URL: "a.jpg", HDURL: "a-hd.jpg"}, "a-hd.jpg", "a.jpg"},
+		{Image{MediaType: MediaImage, URL: "a.jpg"}, "a.jpg", "a.jpg"},
+		{Image{MediaType: MediaVideo, URL: "https://youtu.be/abc"}, "https://img.youtube.com/vi/abc/hqdefault.jpg", "https://img.youtube.com/vi/abc/hqdefault.jpg"},
+		{Image{MediaType: MediaVideo, URL: "https://vimeo.com/123", ThumbnailURL: "t.jpg"}, "t.jpg", "t.jpg"},
+		{Image{MediaType: MediaOther, URL: "https://apod.nasa.gov/page.html"}, "", ""},
+	}
+	for _, v := range tests {
+		if got := v.apod.PictureURL(true); got != v.hd {
+			t.Errorf("PictureURL(true) for %s got %q, want %q", v.apod.URL, got, v.hd)
+		}
+		if got := v.apod.PictureURL(false); got != v.sd {
+			t.Errorf("PictureURL(false) for %s got %q, want %q", v.apod.URL, got, v.sd)
+		}
+	}
+}
+
+func TestVideoAutoplayURL(t *testing.T) {
+	v := ParseVideoURL("https://www.youtube.com/embed/abc?start=30")
+	want := "https://www.youtube.com/embed/abc?autoplay=1&controls=0&loop=1&mute=1&playlist=abc&start=30"
+	if got := v.AutoplayURL(); got != want {
+		t.Errorf("AutoplayURL got %q, want %q", got, want)
+	}
+}